@@ -0,0 +1,195 @@
+// admin/actions.go
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mviner000/eyygo/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// DeleteSelected is the name of the built-in action every model gets
+const DeleteSelected = "delete_selected"
+
+// ErrActionNotFound is returned by RunAction for unknown action names
+var ErrActionNotFound = errors.New("action not found")
+
+// ActionFunc runs a bulk action inside a transaction. Returning an error rolls
+// the whole transaction back; per-row problems belong in ActionResult.Failed.
+type ActionFunc func(ma *ModelAdmin, tx *gorm.DB, ids []string) (*ActionResult, error)
+
+// Action is a named bulk operation on selected entries
+type Action struct {
+	Name  string     `json:"name"`
+	Label string     `json:"label"`
+	Func  ActionFunc `json:"-"`
+}
+
+// ActionResult reports what an action did
+type ActionResult struct {
+	Action   string          `json:"action"`
+	Affected int             `json:"affected"`
+	Failed   []ActionFailure `json:"failed"`
+}
+
+// ActionFailure is a row an action could not process
+type ActionFailure struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// GetActions returns the model's actions followed by the built-in ones
+func (ma *ModelAdmin) GetActions() []Action {
+	actions := append([]Action(nil), ma.Actions...)
+	return append(actions, Action{
+		Name:  DeleteSelected,
+		Label: "Delete selected",
+		Func:  deleteSelected,
+	})
+}
+
+// GetAction looks an action up by name
+func (ma *ModelAdmin) GetAction(name string) (Action, bool) {
+	for _, action := range ma.GetActions() {
+		if action.Name == name {
+			return action, true
+		}
+	}
+	return Action{}, false
+}
+
+// RunAction runs the named action on ids in a single transaction
+func (ma *ModelAdmin) RunAction(ctx context.Context, name string, ids []string) (*ActionResult, error) {
+	action, ok := ma.GetAction(name)
+	if !ok {
+		return nil, ErrActionNotFound
+	}
+
+	var result *ActionResult
+	err := ma.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = action.Func(ma, tx, ids)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		result = &ActionResult{}
+	}
+	result.Action = action.Name
+	if result.Failed == nil {
+		result.Failed = []ActionFailure{}
+	}
+	return result, nil
+}
+
+// Selected returns a query restricted to the entries with the given primary keys,
+// for actions that work on the whole selection at once
+func (ma *ModelAdmin) Selected(tx *gorm.DB, ids []string) (*gorm.DB, error) {
+	s, err := ma.Schema()
+	if err != nil {
+		return nil, err
+	}
+	if s.PrioritizedPrimaryField == nil {
+		return nil, fmt.Errorf("%s has no primary key", ma.Name)
+	}
+
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+	return tx.Model(ma.Model).Where(clause.IN{
+		Column: clause.Column{Table: clause.CurrentTable, Name: s.PrioritizedPrimaryField.DBName},
+		Values: values,
+	}), nil
+}
+
+// EachSelected loads every selected entry and calls fn with it. Each call runs in
+// its own savepoint, so a failing row is rolled back and reported while the others
+// are kept.
+func (ma *ModelAdmin) EachSelected(tx *gorm.DB, ids []string, fn func(tx *gorm.DB, entry interface{}) error) (*ActionResult, error) {
+	result := &ActionResult{}
+	for i, id := range ids {
+		entry, err := ma.FindEntry(tx, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = errors.New("not found")
+			}
+			result.Failed = append(result.Failed, ActionFailure{ID: id, Error: err.Error()})
+			continue
+		}
+
+		savepoint := fmt.Sprintf("action_row_%d", i)
+		if err := tx.SavePoint(savepoint).Error; err != nil {
+			return nil, err
+		}
+		if err := fn(tx, entry); err != nil {
+			if rollbackErr := tx.RollbackTo(savepoint).Error; rollbackErr != nil {
+				return nil, rollbackErr
+			}
+			result.Failed = append(result.Failed, ActionFailure{ID: id, Error: err.Error()})
+			continue
+		}
+		result.Affected++
+	}
+	return result, nil
+}
+
+// BooleanAction builds an action that sets a boolean field on every selected
+// entry, e.g. BooleanAction("activate", "Activate selected", "IsActive", true)
+func BooleanAction(name, label, field string, value bool) Action {
+	return Action{
+		Name:  name,
+		Label: label,
+		Func: func(ma *ModelAdmin, tx *gorm.DB, ids []string) (*ActionResult, error) {
+			schemaField, ok := ma.SchemaField(field)
+			if !ok || schemaField.DataType != schema.Bool {
+				return nil, fmt.Errorf("%s is not a boolean field of %s", field, ma.Name)
+			}
+			return ma.EachSelected(tx, ids, func(tx *gorm.DB, entry interface{}) error {
+				before := ma.Snapshot(entry)
+				if err := tx.Model(entry).Update(schemaField.DBName, value).Error; err != nil {
+					return err
+				}
+				return ma.LogChange(tx, models.ActionUpdate, before, entry)
+			})
+		},
+	}
+}
+
+func deleteSelected(ma *ModelAdmin, tx *gorm.DB, ids []string) (*ActionResult, error) {
+	return ma.EachSelected(tx, ids, func(tx *gorm.DB, entry interface{}) error {
+		if err := tx.Delete(entry).Error; err != nil {
+			return err
+		}
+		return ma.LogChange(tx, models.ActionDelete, entry, nil)
+	})
+}
+
+// ParseIDs normalises submitted IDs (JSON numbers or strings) and drops duplicates
+func ParseIDs(raw []interface{}) []string {
+	seen := make(map[string]bool, len(raw))
+	ids := make([]string, 0, len(raw))
+	for _, value := range raw {
+		var id string
+		switch v := value.(type) {
+		case float64:
+			id = fmt.Sprintf("%.0f", v)
+		default:
+			id = strings.TrimSpace(fmt.Sprint(v))
+		}
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
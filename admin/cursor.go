@@ -0,0 +1,218 @@
+// admin/cursor.go
+package admin
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidCursor is returned for a cursor that cannot be decoded or was
+// issued for another ordering
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor is the position after (or, when Backward, before) a row, encoded in
+// URLs as base64 JSON
+type cursor struct {
+	Ordering string          `json:"o"`
+	Value    json.RawMessage `json:"v,omitempty"` // ordering column of the row
+	Key      json.RawMessage `json:"k"`           // primary key of the row
+	Backward bool            `json:"b,omitempty"`
+}
+
+// KeysetPage is one page of a keyset paginated list
+type KeysetPage struct {
+	Entries interface{} // pointer to a slice of the model
+	Next    string      // cursor of the following page, empty on the last page
+	Prev    string      // cursor of the preceding page, empty on the first page
+}
+
+// KeysetPaginate returns the page of query following params.Cursor (the first
+// page for an empty cursor). Unlike OFFSET, the cost of a page does not grow
+// with its position: rows are selected by comparing the ordering column and
+// the primary key with the last row of the previous page. query must be
+// filtered (see Filter) but not ordered.
+func (ma *ModelAdmin) KeysetPaginate(query *gorm.DB, params ListParams) (*KeysetPage, error) {
+	orderColumn, desc := ma.orderColumn(params.Ordering)
+	pkColumn := ma.primaryColumn()
+	ordering := orderColumn
+	if desc {
+		ordering = "-" + orderColumn
+	}
+
+	var position *cursor
+	if params.Cursor != "" {
+		decoded, err := decodeCursor(params.Cursor)
+		if err != nil || decoded.Ordering != ordering {
+			return nil, ErrInvalidCursor
+		}
+		position = decoded
+	}
+	backward := position != nil && position.Backward
+
+	if position != nil {
+		condition, err := ma.keysetCondition(query, position, orderColumn, pkColumn, desc != backward)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(condition)
+	}
+
+	entries := ma.NewSlice()
+	err := ma.Order(query, params, backward).Limit(params.PerPage + 1).Find(entries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	rows := reflect.ValueOf(entries).Elem()
+	hasMore := rows.Len() > params.PerPage
+	if hasMore {
+		rows.Set(rows.Slice(0, params.PerPage))
+	}
+	if backward {
+		for i, j := 0, rows.Len()-1; i < j; i, j = i+1, j-1 {
+			first, last := rows.Index(i).Interface(), rows.Index(j).Interface()
+			rows.Index(i).Set(reflect.ValueOf(last))
+			rows.Index(j).Set(reflect.ValueOf(first))
+		}
+	}
+
+	page := &KeysetPage{Entries: entries}
+	if rows.Len() == 0 {
+		return page, nil
+	}
+	first := rows.Index(0).Addr().Interface()
+	last := rows.Index(rows.Len() - 1).Addr().Interface()
+
+	// Going forward there is a next page if a row was left over, and a
+	// previous one if we came from a cursor; going backward it is the reverse
+	if hasMore || backward {
+		if page.Next, err = ma.encodeCursor(last, ordering, orderColumn, false); err != nil {
+			return nil, err
+		}
+	}
+	if (backward && hasMore) || (!backward && position != nil) {
+		if page.Prev, err = ma.encodeCursor(first, ordering, orderColumn, true); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// keysetCondition selects the rows after the cursor in the direction given by
+// descending: (column, pk) < (value, key) when descending, > otherwise. NULLs
+// of a nullable ordering column sort where the database puts them: before
+// every value on SQLite and MySQL, after on PostgreSQL.
+func (ma *ModelAdmin) keysetCondition(db *gorm.DB, position *cursor, orderColumn, pkColumn string, descending bool) (clause.Expression, error) {
+	key, err := ma.cursorValue(pkColumn, position.Key)
+	if err != nil {
+		return nil, err
+	}
+	pk := clause.Column{Table: clause.CurrentTable, Name: pkColumn}
+	after := func(column clause.Column, value interface{}) clause.Expression {
+		if descending {
+			return clause.Lt{Column: column, Value: value}
+		}
+		return clause.Gt{Column: column, Value: value}
+	}
+
+	if orderColumn == pkColumn {
+		return after(pk, key), nil
+	}
+	column := clause.Column{Table: clause.CurrentTable, Name: orderColumn}
+	isNull := clause.Eq{Column: column, Value: nil}
+	isNotNull := clause.Neq{Column: column, Value: nil}
+	// Whether NULLs come after the values in the direction of travel
+	nullsAfter := nullsSortHigh(db) != descending
+
+	if string(position.Value) == "null" {
+		if nullsAfter {
+			return clause.And(isNull, after(pk, key)), nil
+		}
+		return clause.Or(isNotNull, clause.And(isNull, after(pk, key))), nil
+	}
+
+	value, err := ma.cursorValue(orderColumn, position.Value)
+	if err != nil {
+		return nil, err
+	}
+	condition := clause.Or(
+		after(column, value),
+		clause.And(clause.Eq{Column: column, Value: value}, after(pk, key)),
+	)
+	if nullsAfter && ma.nullableColumn(orderColumn) {
+		condition = clause.Or(condition, isNull)
+	}
+	return condition, nil
+}
+
+// nullsSortHigh reports whether the database orders NULLs after every value,
+// as PostgreSQL does, rather than before them like SQLite and MySQL
+func nullsSortHigh(db *gorm.DB) bool {
+	return db.Dialector.Name() == "postgres"
+}
+
+// nullableColumn reports whether a column of the model can hold NULL
+func (ma *ModelAdmin) nullableColumn(column string) bool {
+	s, err := ma.Schema()
+	if err != nil {
+		return false
+	}
+	field, ok := s.FieldsByDBName[column]
+	return ok && nullable(field.FieldType)
+}
+
+// cursorValue decodes a value stored in a cursor into the Go type of its
+// column, so it is bound exactly like the stored values (e.g. times)
+func (ma *ModelAdmin) cursorValue(column string, raw json.RawMessage) (interface{}, error) {
+	s, err := ma.Schema()
+	if err != nil {
+		return nil, err
+	}
+	field, ok := s.FieldsByDBName[column]
+	if !ok || len(raw) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	value := reflect.New(field.FieldType)
+	if err := json.Unmarshal(raw, value.Interface()); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return value.Elem().Interface(), nil
+}
+
+func (ma *ModelAdmin) encodeCursor(entry interface{}, ordering, orderColumn string, backward bool) (string, error) {
+	s, err := ma.Schema()
+	if err != nil {
+		return "", err
+	}
+	position := cursor{Ordering: ordering, Backward: backward}
+	if position.Key, err = json.Marshal(ma.PrimaryKey(entry)); err != nil {
+		return "", err
+	}
+	if field, ok := s.FieldsByDBName[orderColumn]; ok && orderColumn != ma.primaryColumn() {
+		if position.Value, err = json.Marshal(FieldValue(entry, field.Name)); err != nil {
+			return "", err
+		}
+	}
+	encoded, err := json.Marshal(position)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+func decodeCursor(raw string) (*cursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	var position cursor
+	if err := json.Unmarshal(decoded, &position); err != nil {
+		return nil, err
+	}
+	return &position, nil
+}
@@ -0,0 +1,207 @@
+// admin/cursor_test.go
+package admin
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// cursorItem is ordered by a nullable column
+type cursorItem struct {
+	ID   uint
+	Rank *int
+}
+
+// openTestDB opens an empty SQLite database migrated for models
+func openTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// newCursorAdmin stores items with NULL ranks among equal and distinct ones
+func newCursorAdmin(t *testing.T) (*ModelAdmin, []cursorItem) {
+	db := openTestDB(t, &cursorItem{})
+	rank := func(r int) *int { return &r }
+	items := []cursorItem{
+		{Rank: nil}, {Rank: rank(2)}, {Rank: nil}, {Rank: rank(1)},
+		{Rank: rank(2)}, {Rank: nil}, {Rank: rank(3)},
+	}
+	if err := db.Create(&items).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &ModelAdmin{Model: &cursorItem{}, OrderFields: []string{"Rank"}, DB: db}, items
+}
+
+// sortedIDs orders items like SQLite does: NULLs before every rank, ties
+// broken by ID, all reversed when descending
+func sortedIDs(items []cursorItem, descending bool) []uint {
+	sorted := append([]cursorItem(nil), items...)
+	less := func(a, b cursorItem) bool {
+		switch {
+		case a.Rank == nil && b.Rank == nil:
+			return a.ID < b.ID
+		case a.Rank == nil:
+			return true
+		case b.Rank == nil:
+			return false
+		case *a.Rank != *b.Rank:
+			return *a.Rank < *b.Rank
+		}
+		return a.ID < b.ID
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if descending {
+			return less(sorted[j], sorted[i])
+		}
+		return less(sorted[i], sorted[j])
+	})
+	ids := make([]uint, len(sorted))
+	for i, item := range sorted {
+		ids[i] = item.ID
+	}
+	return ids
+}
+
+func pageIDs(page *KeysetPage) []uint {
+	entries := *page.Entries.(*[]cursorItem)
+	ids := make([]uint, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+	return ids
+}
+
+func TestKeysetPaginateNullOrdering(t *testing.T) {
+	for _, ordering := range []string{"rank", "-rank"} {
+		for _, perPage := range []int{1, 2, 3} {
+			ma, items := newCursorAdmin(t)
+			want := sortedIDs(items, ordering == "-rank")
+			params := ListParams{Ordering: ordering, PerPage: perPage, Keyset: true}
+
+			// Walk forward to the last page, then backward to the first
+			var forward []uint
+			var pages []*KeysetPage
+			for cursor := ""; ; {
+				params.Cursor = cursor
+				page, err := ma.KeysetPaginate(ma.DB.Model(&cursorItem{}), params)
+				if err != nil {
+					t.Fatalf("%s by %d: %v", ordering, perPage, err)
+				}
+				pages = append(pages, page)
+				forward = append(forward, pageIDs(page)...)
+				if page.Next == "" {
+					break
+				}
+				if len(pages) > len(items) {
+					t.Fatalf("%s by %d: the walk does not end", ordering, perPage)
+				}
+				cursor = page.Next
+			}
+			if !reflect.DeepEqual(forward, want) {
+				t.Errorf("%s by %d forward: got %v, want %v", ordering, perPage, forward, want)
+			}
+
+			var backward []uint
+			for i := len(pages) - 1; i > 0; i-- {
+				params.Cursor = pages[i].Prev
+				page, err := ma.KeysetPaginate(ma.DB.Model(&cursorItem{}), params)
+				if err != nil {
+					t.Fatalf("%s by %d: %v", ordering, perPage, err)
+				}
+				if got, want := pageIDs(page), pageIDs(pages[i-1]); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s by %d backward from page %d: got %v, want %v", ordering, perPage, i+1, got, want)
+				}
+				if i == 1 && page.Prev != "" {
+					t.Errorf("%s by %d: the first page has a previous cursor", ordering, perPage)
+				}
+				backward = append(pageIDs(page), backward...)
+			}
+			backward = append(backward, pageIDs(pages[len(pages)-1])...)
+			if !reflect.DeepEqual(backward, want) {
+				t.Errorf("%s by %d backward: got %v, want %v", ordering, perPage, backward, want)
+			}
+		}
+	}
+}
+
+func TestKeysetPaginateRejectsCursorOfAnotherOrdering(t *testing.T) {
+	ma, _ := newCursorAdmin(t)
+	page, err := ma.KeysetPaginate(ma.DB.Model(&cursorItem{}), ListParams{Ordering: "rank", PerPage: 2, Keyset: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := ListParams{Ordering: "-rank", PerPage: 2, Keyset: true, Cursor: page.Next}
+	if _, err := ma.KeysetPaginate(ma.DB.Model(&cursorItem{}), params); err != ErrInvalidCursor {
+		t.Errorf("got %v, want ErrInvalidCursor", err)
+	}
+}
+
+// postgresOrder is SQLite named as PostgreSQL, which sorts NULLs last
+type postgresOrder struct {
+	gorm.Dialector
+}
+
+func (postgresOrder) Name() string {
+	return "postgres"
+}
+
+func TestKeysetConditionNulls(t *testing.T) {
+	tests := []struct {
+		postgres   bool
+		descending bool
+		value      string
+		want       string
+	}{
+		{false, false, "2", "(`cursor_items`.`rank` > 2 OR (`cursor_items`.`rank` = 2 AND `cursor_items`.`id` > 5))"},
+		{false, true, "2", "((`cursor_items`.`rank` < 2 OR (`cursor_items`.`rank` = 2 AND `cursor_items`.`id` < 5)) OR `cursor_items`.`rank` IS NULL)"},
+		{false, false, "null", "(`cursor_items`.`rank` IS NOT NULL OR (`cursor_items`.`rank` IS NULL AND `cursor_items`.`id` > 5))"},
+		{false, true, "null", "`cursor_items`.`rank` IS NULL AND `cursor_items`.`id` < 5"},
+		{true, false, "2", "((`cursor_items`.`rank` > 2 OR (`cursor_items`.`rank` = 2 AND `cursor_items`.`id` > 5)) OR `cursor_items`.`rank` IS NULL)"},
+		{true, true, "2", "(`cursor_items`.`rank` < 2 OR (`cursor_items`.`rank` = 2 AND `cursor_items`.`id` < 5))"},
+		{true, false, "null", "`cursor_items`.`rank` IS NULL AND `cursor_items`.`id` > 5"},
+		{true, true, "null", "(`cursor_items`.`rank` IS NOT NULL OR (`cursor_items`.`rank` IS NULL AND `cursor_items`.`id` < 5))"},
+	}
+
+	ma, _ := newCursorAdmin(t)
+	for _, tt := range tests {
+		db := ma.DB
+		if tt.postgres {
+			db = &gorm.DB{Config: &gorm.Config{Dialector: postgresOrder{ma.DB.Dialector}}}
+		}
+		position := &cursor{Value: json.RawMessage(tt.value), Key: json.RawMessage("5")}
+		condition, err := ma.keysetCondition(db, position, "rank", "id", tt.descending)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := ma.DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			return tx.Model(&cursorItem{}).Where(condition).Find(&[]cursorItem{})
+		})
+		want := "SELECT * FROM `cursor_items` WHERE " + tt.want
+		if got != want {
+			t.Errorf("postgres=%v descending=%v value=%s:\n got %s\nwant %s", tt.postgres, tt.descending, tt.value, got, want)
+		}
+	}
+}
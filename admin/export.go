@@ -0,0 +1,260 @@
+// admin/export.go
+package admin
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// Export formats
+const (
+	ExportCSV  = "csv"
+	ExportJSON = "json"
+	ExportXLSX = "xlsx"
+)
+
+// ExportContentTypes maps each export format to its MIME type
+var ExportContentTypes = map[string]string{
+	ExportCSV:  "text/csv; charset=utf-8",
+	ExportJSON: "application/json",
+	ExportXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// exporter writes rows in one export format
+type exporter interface {
+	header(columns []Field) error
+	row(values []interface{}) error
+	close() error
+}
+
+// ExportColumns returns the ListFields that are stored in the table, leaving out
+// relations and sensitive fields
+func (ma *ModelAdmin) ExportColumns() []Field {
+	var columns []Field
+	for _, field := range ma.ListColumns() {
+		if field.DBName == "" || IsSensitive(field.Name) {
+			continue
+		}
+		columns = append(columns, field)
+	}
+	return columns
+}
+
+// Export writes every row matched by query to w. Rows are read from a cursor
+// one at a time, so memory use does not grow with the table.
+func (ma *ModelAdmin) Export(w io.Writer, query *gorm.DB, format string) error {
+	var out exporter
+	switch format {
+	case ExportCSV:
+		out = &csvExporter{writer: csv.NewWriter(w)}
+	case ExportJSON:
+		out = &jsonExporter{writer: w}
+	case ExportXLSX:
+		out = newXLSXExporter(w)
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+
+	columns := ma.ExportColumns()
+	if err := out.header(columns); err != nil {
+		return err
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	values := make([]interface{}, len(columns))
+	for rows.Next() {
+		entry := ma.NewEntry()
+		if err := query.ScanRows(rows, entry); err != nil {
+			return err
+		}
+		for i, column := range columns {
+			values[i] = exportValue(FieldValue(entry, column.Name))
+		}
+		if err := out.row(values); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return out.close()
+}
+
+// exportValue dereferences pointers and unwraps nullable types
+func exportValue(value interface{}) interface{} {
+	if valuer, ok := value.(interface{ Value() (interface{}, error) }); ok {
+		if v, err := valuer.Value(); err == nil {
+			return v
+		}
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		return exportValue(rv.Elem().Interface())
+	}
+	return value
+}
+
+type csvExporter struct {
+	writer *csv.Writer
+}
+
+func (e *csvExporter) header(columns []Field) error {
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		record[i] = column.DBName
+	}
+	return e.writer.Write(record)
+}
+
+func (e *csvExporter) row(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case bool:
+			record[i] = strconv.FormatBool(v)
+		case time.Time:
+			if !v.IsZero() {
+				record[i] = v.Format(time.RFC3339)
+			}
+		default:
+			record[i] = FormatValue(value, "")
+		}
+	}
+	if err := e.writer.Write(record); err != nil {
+		return err
+	}
+	// Flush per row so the response streams instead of buffering
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvExporter) close() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type jsonExporter struct {
+	writer  io.Writer
+	keys    [][]byte
+	started bool
+}
+
+func (e *jsonExporter) header(columns []Field) error {
+	for _, column := range columns {
+		key, err := json.Marshal(column.DBName)
+		if err != nil {
+			return err
+		}
+		e.keys = append(e.keys, key)
+	}
+	_, err := io.WriteString(e.writer, "[")
+	return err
+}
+
+// row writes one object with the keys in column order
+func (e *jsonExporter) row(values []interface{}) error {
+	buf := make([]byte, 0, 256)
+	if e.started {
+		buf = append(buf, ',')
+	}
+	e.started = true
+
+	buf = append(buf, "\n{"...)
+	for i, value := range values {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, e.keys[i]...)
+		buf = append(buf, ':')
+		buf = append(buf, encoded...)
+	}
+	buf = append(buf, '}')
+
+	_, err := e.writer.Write(buf)
+	return err
+}
+
+func (e *jsonExporter) close() error {
+	_, err := io.WriteString(e.writer, "\n]\n")
+	return err
+}
+
+// xlsxExporter uses excelize's stream writer, which spills rows to a temporary
+// file instead of keeping the sheet in memory
+type xlsxExporter struct {
+	writer io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	rows   int
+}
+
+func newXLSXExporter(w io.Writer) *xlsxExporter {
+	return &xlsxExporter{writer: w, file: excelize.NewFile()}
+}
+
+// header uses the readable labels, as the sheet is meant for people
+func (e *xlsxExporter) header(columns []Field) error {
+	stream, err := e.file.NewStreamWriter("Sheet1")
+	if err != nil {
+		return err
+	}
+	e.stream = stream
+
+	cells := make([]interface{}, len(columns))
+	for i, column := range columns {
+		cells[i] = column.Label
+	}
+	return e.writeRow(cells)
+}
+
+func (e *xlsxExporter) row(values []interface{}) error {
+	cells := make([]interface{}, len(values))
+	for i, value := range values {
+		switch value.(type) {
+		case nil, string, bool, time.Time,
+			int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			cells[i] = value
+		default:
+			cells[i] = FormatValue(value, "")
+		}
+	}
+	return e.writeRow(cells)
+}
+
+func (e *xlsxExporter) writeRow(cells []interface{}) error {
+	e.rows++
+	cell, err := excelize.CoordinatesToCellName(1, e.rows)
+	if err != nil {
+		return err
+	}
+	return e.stream.SetRow(cell, cells)
+}
+
+func (e *xlsxExporter) close() error {
+	defer e.file.Close()
+	if err := e.stream.Flush(); err != nil {
+		return err
+	}
+	return e.file.Write(e.writer)
+}
@@ -0,0 +1,283 @@
+// admin/fields.go
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Input types used by generated forms
+const (
+	InputText     = "text"
+	InputTextarea = "textarea"
+	InputNumber   = "number"
+	InputCheckbox = "checkbox"
+	InputDateTime = "datetime-local"
+	InputPassword = "password"
+	InputSelect   = "select"
+	InputMarkdown = "markdown" // textarea with a rendered preview
+)
+
+// dateTimeInputLayout is the value format of <input type="datetime-local">
+const dateTimeInputLayout = "2006-01-02T15:04"
+
+// Field describes a model field for generated lists and forms
+type Field struct {
+	Name      string   `json:"name"`
+	DBName    string   `json:"db_name"`
+	Label     string   `json:"label"`
+	Input     string   `json:"input"`
+	Required  bool     `json:"required"`
+	MaxLength int      `json:"max_length,omitempty"`
+	Related   string   `json:"related,omitempty"` // model picked by a relation input
+	Choices   []Choice `json:"choices,omitempty"` // options of a select input
+}
+
+// Schema parses the model with GORM's schema parser; the result is cached
+func (ma *ModelAdmin) Schema() (*schema.Schema, error) {
+	ma.schemaOnce.Do(func() {
+		ma.schema, ma.schemaErr = schema.Parse(ma.Model, &sync.Map{}, ma.DB.NamingStrategy)
+	})
+	return ma.schema, ma.schemaErr
+}
+
+// SchemaField returns the GORM field for a Go field name
+func (ma *ModelAdmin) SchemaField(name string) (*schema.Field, bool) {
+	s, err := ma.Schema()
+	if err != nil {
+		return nil, false
+	}
+	field, exists := s.FieldsByName[name]
+	return field, exists
+}
+
+// NewEntry returns a pointer to a new zero value of the model
+func (ma *ModelAdmin) NewEntry() interface{} {
+	return reflect.New(reflect.Indirect(reflect.ValueOf(ma.Model)).Type()).Interface()
+}
+
+// FindEntry loads the entry with the given primary key. The key is always bound
+// as a parameter, never interpolated into the query.
+func (ma *ModelAdmin) FindEntry(db *gorm.DB, id string) (interface{}, error) {
+	s, err := ma.Schema()
+	if err != nil {
+		return nil, err
+	}
+	if s.PrioritizedPrimaryField == nil {
+		return nil, fmt.Errorf("%s has no primary key", ma.Name)
+	}
+
+	entry := ma.NewEntry()
+	err = db.Where(clause.Eq{
+		Column: clause.Column{Table: clause.CurrentTable, Name: s.PrioritizedPrimaryField.DBName},
+		Value:  id,
+	}).First(entry).Error
+	return entry, err
+}
+
+// NewSlice returns a pointer to an empty slice of the model, ready for Find
+func (ma *ModelAdmin) NewSlice() interface{} {
+	modelType := reflect.Indirect(reflect.ValueOf(ma.Model)).Type()
+	return reflect.New(reflect.SliceOf(modelType)).Interface()
+}
+
+// entriesOf returns pointers to the elements of a slice created by NewSlice
+func entriesOf(slice interface{}) []interface{} {
+	value := reflect.Indirect(reflect.ValueOf(slice))
+	entries := make([]interface{}, value.Len())
+	for i := range entries {
+		entries[i] = value.Index(i).Addr().Interface()
+	}
+	return entries
+}
+
+// ListColumns describes the ListFields
+func (ma *ModelAdmin) ListColumns() []Field {
+	return ma.describe(ma.ListFields)
+}
+
+// FormColumns describes the FormFields
+func (ma *ModelAdmin) FormColumns() []Field {
+	return ma.describe(ma.FormFields)
+}
+
+func (ma *ModelAdmin) describe(names []string) []Field {
+	fields := make([]Field, 0, len(names))
+	for _, name := range names {
+		field := Field{Name: name, Label: Label(name), Input: InputText}
+		if sf, ok := ma.SchemaField(name); ok {
+			field.DBName = sf.DBName
+			field.Input = inputType(sf)
+			field.Required = sf.NotNull && !sf.HasDefaultValue && sf.DataType != schema.Bool
+			if sf.DataType == schema.String {
+				field.MaxLength = sf.Size
+			}
+			if related, ok := ma.RelatedModel(field); ok {
+				field.Input = InputRelation
+				field.Related = related.Name
+				// A foreign key that is not a pointer cannot be left empty
+				field.Required = field.Required || sf.FieldType.Kind() != reflect.Ptr
+			}
+			if slices.Contains(ma.MarkdownFields, name) {
+				field.Input = InputMarkdown
+			}
+			if choices, ok := ma.Choices[name]; ok {
+				field.Input = InputSelect
+				field.Choices = choices
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func inputType(field *schema.Field) string {
+	switch {
+	case strings.Contains(strings.ToLower(field.Name), "password"):
+		return InputPassword
+	case field.DataType == schema.Bool:
+		return InputCheckbox
+	case field.DataType == schema.Int || field.DataType == schema.Uint || field.DataType == schema.Float:
+		return InputNumber
+	case field.DataType == schema.Time:
+		return InputDateTime
+	case field.GORMDataType == schema.String && (field.Size > 255 || strings.EqualFold(field.TagSettings["TYPE"], "text")):
+		return InputTextarea
+	}
+	return InputText
+}
+
+// SensitiveFields are fragments of field names whose values are never exported
+// and are redacted in the change log
+var SensitiveFields = []string{"password", "secret", "token"}
+
+// IsSensitive reports whether a field name matches SensitiveFields
+func IsSensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, fragment := range SensitiveFields {
+		if strings.Contains(name, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns value as it encodes to JSON, without the sensitive fields of
+// any object in it, those of preloaded relations included, so that entries can
+// be returned by the API without their password hashes
+func Redact(value interface{}) interface{} {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil
+	}
+	return redactSensitive(decoded)
+}
+
+func redactSensitive(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if IsSensitive(key) {
+				delete(value, key)
+				continue
+			}
+			value[key] = redactSensitive(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redactSensitive(item)
+		}
+	}
+	return value
+}
+
+var wordBoundary = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+
+// Label turns a Go field name into a human readable label, e.g. "IsPublished" -> "Is published"
+func Label(name string) string {
+	if name == "ID" {
+		return name
+	}
+	words := strings.ToLower(wordBoundary.ReplaceAllString(name, "$1 $2"))
+	if strings.HasSuffix(words, " id") {
+		words = strings.TrimSuffix(words, "id") + "ID"
+	}
+	return strings.ToUpper(words[:1]) + words[1:]
+}
+
+// FieldValue returns the value of a Go field of entry, or nil if it does not exist
+func FieldValue(entry interface{}, name string) interface{} {
+	value := reflect.Indirect(reflect.ValueOf(entry)).FieldByName(name)
+	if !value.IsValid() {
+		return nil
+	}
+	return value.Interface()
+}
+
+// FormatValue renders a field value for display in a list or a form input
+func FormatValue(value interface{}, input string) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		if input == InputDateTime {
+			return v.Format(dateTimeInputLayout)
+		}
+		return v.Format("2006-01-02 15:04")
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return FormatValue(*v, input)
+	case bool:
+		if v {
+			return "Yes"
+		}
+		return "No"
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return ""
+		}
+		return FormatValue(rv.Elem().Interface(), input)
+	case reflect.Struct:
+		// Related objects are shown by their primary key
+		if id := rv.FieldByName("ID"); id.IsValid() {
+			return fmt.Sprint(id.Interface())
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+// ParseFormValue converts a submitted form value into something GORM's field setter accepts
+func ParseFormValue(field Field, raw string) string {
+	if field.Input == InputDateTime {
+		if t, err := time.ParseInLocation(dateTimeInputLayout, raw, time.Local); err == nil {
+			return t.Format("2006-01-02 15:04:05")
+		}
+	}
+	return raw
+}
@@ -0,0 +1,113 @@
+// admin/forms.go
+package admin
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldErrors maps a field label to what is wrong with its value
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	labels := make([]string, 0, len(e))
+	for label := range e {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	messages := make([]string, len(labels))
+	for i, label := range labels {
+		messages[i] = label + ": " + e[label]
+	}
+	return strings.Join(messages, "; ")
+}
+
+// SetValues validates submitted values of FormFields, keyed by column name,
+// and assigns them to entry. Fields missing from values are left unchanged,
+// as is a sensitive field submitted empty on an existing entry. When adding,
+// required fields must be present.
+func (ma *ModelAdmin) SetValues(ctx context.Context, entry interface{}, values map[string]string, adding bool) error {
+	target := reflect.ValueOf(entry).Elem()
+	errs := FieldErrors{}
+
+	for _, field := range ma.FormColumns() {
+		schemaField, ok := ma.SchemaField(field.Name)
+		if !ok || field.DBName == "" {
+			continue
+		}
+
+		raw, present := values[field.DBName]
+		raw = strings.TrimSpace(raw)
+		if !present && !adding {
+			continue
+		}
+		if raw == "" && IsSensitive(field.Name) && !adding {
+			continue
+		}
+		if raw == "" && field.Required {
+			errs[field.Label] = "this field is required"
+			continue
+		}
+		if field.MaxLength > 0 && utf8.RuneCountInString(raw) > field.MaxLength {
+			errs[field.Label] = fmt.Sprintf("at most %d characters", field.MaxLength)
+			continue
+		}
+
+		switch field.Input {
+		case InputSelect:
+			if raw != "" && !hasChoice(field.Choices, raw) {
+				errs[field.Label] = fmt.Sprintf("%q is not a valid choice", raw)
+				continue
+			}
+		case InputCheckbox:
+			b, ok := parseBool(raw)
+			if !ok {
+				errs[field.Label] = fmt.Sprintf("invalid boolean %q", raw)
+				continue
+			}
+			raw = fmt.Sprint(b)
+		case InputNumber:
+			if raw == "" {
+				raw = "0"
+			}
+		}
+
+		var value interface{} = ParseFormValue(field, raw)
+		if raw == "" && schemaField.FieldType.Kind() == reflect.Ptr {
+			value = nil // an optional value left blank, such as a date
+		}
+		if err := schemaField.Set(ctx, target, value); err != nil {
+			errs[field.Label] = err.Error()
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func hasChoice(choices []Choice, value string) bool {
+	for _, choice := range choices {
+		if fmt.Sprint(choice.ID) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBool accepts the spellings found in forms and spreadsheets
+func parseBool(raw string) (bool, bool) {
+	switch strings.ToLower(raw) {
+	case "1", "t", "true", "y", "yes", "on":
+		return true, true
+	case "", "0", "f", "false", "n", "no", "off":
+		return false, true
+	}
+	return false, false
+}
@@ -0,0 +1,162 @@
+// admin/history.go
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/mviner000/eyygo/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// redactedValue replaces sensitive values (passwords) in the change log
+const redactedValue = "[REDACTED]"
+
+// WithUserID returns a context carrying the acting user, so changes made with it
+// are attributed in the log
+func WithUserID(ctx context.Context, userID uint) context.Context {
+	return models.WithUserID(ctx, userID)
+}
+
+// UserIDFromContext returns the user stored by WithUserID
+func UserIDFromContext(ctx context.Context) (uint, bool) {
+	return models.UserIDFromContext(ctx)
+}
+
+// FieldChange is the old and new value of a changed column
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// Snapshot copies an entry so it can be compared after it has been modified
+func (ma *ModelAdmin) Snapshot(entry interface{}) interface{} {
+	snapshot := ma.NewEntry()
+	reflect.ValueOf(snapshot).Elem().Set(reflect.Indirect(reflect.ValueOf(entry)))
+	return snapshot
+}
+
+// Diff compares two versions of an entry column by column. Either side may be
+// nil (creation or deletion). Timestamps maintained by GORM and columns hidden
+// from JSON, such as cached renderings, are left out and sensitive values are
+// redacted.
+func (ma *ModelAdmin) Diff(before, after interface{}) (map[string]FieldChange, error) {
+	s, err := ma.Schema()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]FieldChange)
+	for _, field := range s.Fields {
+		if field.DBName == "" || field.AutoCreateTime != 0 || field.AutoUpdateTime != 0 || isDeletedAt(field) || jsonName(field) == "" {
+			continue
+		}
+
+		var oldValue, newValue interface{}
+		if before != nil {
+			oldValue = FieldValue(before, field.Name)
+		}
+		if after != nil {
+			newValue = FieldValue(after, field.Name)
+		}
+		if before != nil && after != nil && reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+
+		if IsSensitive(field.Name) {
+			oldValue, newValue = redact(oldValue), redact(newValue)
+		}
+		changes[field.DBName] = FieldChange{Old: oldValue, New: newValue}
+	}
+	return changes, nil
+}
+
+// LogChange records an audit entry for entry within tx. before is nil for
+// creations and after is nil for deletions. The acting user is read from the
+// context of tx (see WithUserID).
+func (ma *ModelAdmin) LogChange(tx *gorm.DB, action string, before, after interface{}) error {
+	entry := after
+	if entry == nil {
+		entry = before
+	}
+
+	changes, err := ma.Diff(before, after)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	logEntry := models.LogEntry{
+		ActionTime: time.Now(),
+		Model:      ma.Name,
+		ObjectID:   fmt.Sprint(ma.PrimaryKey(entry)),
+		Action:     action,
+		Changes:    models.JSONText(encoded),
+	}
+	if userID, ok := UserIDFromContext(tx.Statement.Context); ok {
+		logEntry.UserID = &userID
+	}
+	return tx.Create(&logEntry).Error
+}
+
+// CreateEntry inserts entry and logs the creation in one transaction
+func (ma *ModelAdmin) CreateEntry(ctx context.Context, entry interface{}) error {
+	return ma.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+		return ma.LogChange(tx, models.ActionCreate, nil, entry)
+	})
+}
+
+// UpdateEntry saves entry and logs its changes since before (see Snapshot) in one
+// transaction. Related changes, e.g. saving inlines, can join it through also.
+func (ma *ModelAdmin) UpdateEntry(ctx context.Context, before, entry interface{}, also ...func(tx *gorm.DB) error) error {
+	return ma.UpdateEntryIfMatch(ctx, "", before, entry, also...)
+}
+
+// DeleteEntry deletes entry and logs the deletion in one transaction
+func (ma *ModelAdmin) DeleteEntry(ctx context.Context, entry interface{}) error {
+	return ma.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(entry).Error; err != nil {
+			return err
+		}
+		return ma.LogChange(tx, models.ActionDelete, entry, nil)
+	})
+}
+
+// History returns the audit entries of an object, most recent first
+func (ma *ModelAdmin) History(db *gorm.DB, objectID string) ([]models.LogEntry, error) {
+	var entries []models.LogEntry
+	err := db.Where("model = ? AND object_id = ?", ma.Name, objectID).
+		Order("action_time DESC").Order("id DESC").
+		Find(&entries).Error
+	return entries, err
+}
+
+// PrimaryKey returns the primary key value of entry
+func (ma *ModelAdmin) PrimaryKey(entry interface{}) interface{} {
+	s, err := ma.Schema()
+	if err != nil || s.PrioritizedPrimaryField == nil {
+		return FieldValue(entry, "ID")
+	}
+	return FieldValue(entry, s.PrioritizedPrimaryField.Name)
+}
+
+func isDeletedAt(field *schema.Field) bool {
+	return field.FieldType == reflect.TypeOf(gorm.DeletedAt{})
+}
+
+func redact(value interface{}) interface{} {
+	if value == nil || reflect.ValueOf(value).IsZero() {
+		return value
+	}
+	return redactedValue
+}
@@ -0,0 +1,298 @@
+// admin/import.go
+package admin
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/mviner000/eyygo/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Outcomes of an imported row
+const (
+	ImportCreate = "create"
+	ImportUpdate = "update"
+	ImportError  = "error"
+)
+
+// errImportRollback aborts the import transaction after a dry run or a failed row
+var errImportRollback = errors.New("import rolled back")
+
+// ImportRowResult reports what happened, or would happen, to one row
+type ImportRowResult struct {
+	Row    int         `json:"row"` // 1-based, not counting the CSV header
+	Action string      `json:"action"`
+	ID     interface{} `json:"id,omitempty"`
+	Errors FieldErrors `json:"errors,omitempty"`
+}
+
+// ImportResult summarises an import. Nothing is saved when DryRun is set or
+// when any row failed.
+type ImportResult struct {
+	DryRun         bool              `json:"dry_run"`
+	Committed      bool              `json:"committed"`
+	Key            string            `json:"key"`
+	Created        int               `json:"created"`
+	Updated        int               `json:"updated"`
+	Failed         int               `json:"failed"`
+	IgnoredColumns []string          `json:"ignored_columns"`
+	Rows           []ImportRowResult `json:"rows"`
+}
+
+// ReadImport parses a CSV file (with a header row) or a JSON array of objects
+// into rows keyed by column name
+func ReadImport(r io.Reader, format string) ([]map[string]string, error) {
+	switch format {
+	case ExportCSV:
+		return readCSV(r)
+	case ExportJSON:
+		return readJSON(r)
+	}
+	return nil, fmt.Errorf("unsupported import format %q", format)
+}
+
+func readCSV(r io.Reader) ([]map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Spreadsheets often save a byte order mark
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(record) {
+				row[strings.TrimSpace(column)] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+func readJSON(r io.Reader) ([]map[string]string, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	var objects []map[string]interface{}
+	if err := decoder.Decode(&objects); err != nil {
+		return nil, fmt.Errorf("expected a JSON array of objects: %w", err)
+	}
+
+	rows := make([]map[string]string, len(objects))
+	for i, object := range objects {
+		row := make(map[string]string, len(object))
+		for column, value := range object {
+			if value != nil {
+				row[column] = fmt.Sprint(value)
+			}
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// importKey returns the column rows are matched on: key if given (a field name
+// or column), otherwise the model's ImportKey, otherwise the primary key
+func (ma *ModelAdmin) importKey(key string) (Field, error) {
+	if key == "" {
+		key = ma.ImportKey
+	}
+	s, err := ma.Schema()
+	if err != nil {
+		return Field{}, err
+	}
+	if key == "" && s.PrioritizedPrimaryField != nil {
+		key = s.PrioritizedPrimaryField.Name
+	}
+
+	schemaField, ok := s.FieldsByName[key]
+	if !ok {
+		schemaField, ok = s.FieldsByDBName[key]
+	}
+	if !ok || schemaField.DBName == "" {
+		return Field{}, fmt.Errorf("unknown import key %q", key)
+	}
+	return ma.describe([]string{schemaField.Name})[0], nil
+}
+
+// Import creates or updates one entry per row, matching existing entries on
+// the key column. Rows are validated like the admin forms (see SetValues).
+// Everything runs in one transaction, which is only committed when no row
+// failed and dryRun is false; the changes are recorded in the audit log.
+func (ma *ModelAdmin) Import(ctx context.Context, rows []map[string]string, key string, dryRun bool) (*ImportResult, error) {
+	keyField, err := ma.importKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{
+		DryRun:         dryRun,
+		Key:            keyField.DBName,
+		IgnoredColumns: ma.ignoredColumns(rows, keyField),
+		Rows:           make([]ImportRowResult, 0, len(rows)),
+	}
+	columns := ma.importColumns()
+
+	err = ma.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, row := range rows {
+			values := make(map[string]string, len(row))
+			for column, value := range row {
+				if field, ok := columns[strings.ToLower(strings.TrimSpace(column))]; ok {
+					values[field.DBName] = value
+				}
+			}
+
+			savepoint := fmt.Sprintf("import_row_%d", i)
+			if err := tx.SavePoint(savepoint).Error; err != nil {
+				return err
+			}
+			rowResult, err := ma.importRow(tx, keyField, strings.TrimSpace(columnValue(row, keyField)), values)
+			rowResult.Row = i + 1
+			if err != nil {
+				if rollbackErr := tx.RollbackTo(savepoint).Error; rollbackErr != nil {
+					return rollbackErr
+				}
+				rowResult.Action = ImportError
+				if !errors.As(err, &rowResult.Errors) {
+					rowResult.Errors = FieldErrors{"row": err.Error()}
+				}
+			}
+
+			switch rowResult.Action {
+			case ImportCreate:
+				result.Created++
+			case ImportUpdate:
+				result.Updated++
+			default:
+				result.Failed++
+			}
+			result.Rows = append(result.Rows, rowResult)
+		}
+
+		if dryRun || result.Failed > 0 {
+			return errImportRollback
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportRollback) {
+		return nil, err
+	}
+
+	result.Committed = err == nil
+	return result, nil
+}
+
+func (ma *ModelAdmin) importRow(tx *gorm.DB, keyField Field, keyValue string, values map[string]string) (ImportRowResult, error) {
+	var rowResult ImportRowResult
+
+	entry := ma.NewEntry()
+	adding := true
+	if keyValue != "" {
+		err := tx.Where(clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: keyField.DBName},
+			Value:  keyValue,
+		}).First(entry).Error
+		switch {
+		case err == nil:
+			adding = false
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			entry = ma.NewEntry()
+		default:
+			return rowResult, err
+		}
+	}
+
+	before := ma.Snapshot(entry)
+	if err := ma.SetValues(tx.Statement.Context, entry, values, adding); err != nil {
+		return rowResult, err
+	}
+
+	if adding {
+		rowResult.Action = ImportCreate
+		if err := tx.Create(entry).Error; err != nil {
+			return rowResult, err
+		}
+		rowResult.ID = ma.PrimaryKey(entry)
+		return rowResult, ma.LogChange(tx, models.ActionCreate, nil, entry)
+	}
+
+	rowResult.Action = ImportUpdate
+	rowResult.ID = ma.PrimaryKey(entry)
+	if err := tx.Save(entry).Error; err != nil {
+		return rowResult, err
+	}
+	return rowResult, ma.LogChange(tx, models.ActionUpdate, before, entry)
+}
+
+// importColumns maps the accepted spellings of each FormFields column (column
+// name, field name or label, case-insensitively) to the field
+func (ma *ModelAdmin) importColumns() map[string]Field {
+	columns := make(map[string]Field)
+	for _, field := range ma.FormColumns() {
+		if field.DBName == "" {
+			continue
+		}
+		for _, name := range []string{field.DBName, field.Name, field.Label} {
+			columns[strings.ToLower(name)] = field
+		}
+	}
+	return columns
+}
+
+// ignoredColumns lists the columns of rows that are neither FormFields nor the key
+func (ma *ModelAdmin) ignoredColumns(rows []map[string]string, keyField Field) []string {
+	columns := ma.importColumns()
+	seen := make(map[string]bool)
+	ignored := []string{}
+	for _, row := range rows {
+		for column := range row {
+			normalized := strings.ToLower(strings.TrimSpace(column))
+			if _, ok := columns[normalized]; ok || matchesField(normalized, keyField) || seen[column] {
+				continue
+			}
+			seen[column] = true
+			ignored = append(ignored, column)
+		}
+	}
+	sort.Strings(ignored)
+	return ignored
+}
+
+// columnValue returns the value of field in row, whichever spelling the column uses
+func columnValue(row map[string]string, field Field) string {
+	for column, value := range row {
+		if matchesField(strings.ToLower(strings.TrimSpace(column)), field) {
+			return value
+		}
+	}
+	return ""
+}
+
+func matchesField(normalized string, field Field) bool {
+	return normalized == strings.ToLower(field.DBName) || normalized == strings.ToLower(field.Name) || normalized == strings.ToLower(field.Label)
+}
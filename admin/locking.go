@@ -0,0 +1,130 @@
+// admin/locking.go
+package admin
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mviner000/eyygo/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ConflictError is returned when an entry changed since the version the client
+// edited; Current is the entry as stored
+type ConflictError struct {
+	Current interface{}
+}
+
+func (e *ConflictError) Error() string {
+	return "the entry was changed by someone else"
+}
+
+// ETag identifies the stored version of an entry: its update time when GORM
+// maintains one, otherwise a hash of its columns
+func (ma *ModelAdmin) ETag(entry interface{}) string {
+	s, err := ma.Schema()
+	if err != nil {
+		return ""
+	}
+	for _, field := range s.Fields {
+		if field.AutoUpdateTime == 0 || field.DBName == "" {
+			continue
+		}
+		switch value := exportValue(FieldValue(entry, field.Name)).(type) {
+		case time.Time:
+			return strconv.Quote(strconv.FormatInt(value.UnixNano(), 36))
+		case nil:
+			continue
+		default:
+			return strconv.Quote(fmt.Sprint(value))
+		}
+	}
+
+	hash := sha1.New()
+	for _, field := range s.Fields {
+		if field.DBName != "" {
+			fmt.Fprintf(hash, "%s=%v;", field.DBName, exportValue(FieldValue(entry, field.Name)))
+		}
+	}
+	return strconv.Quote(hex.EncodeToString(hash.Sum(nil)))
+}
+
+// MatchesETag reports whether an If-Match header value matches the ETag of
+// entry. Weak validators are compared by their value; "*" matches any entry.
+func (ma *ModelAdmin) MatchesETag(entry interface{}, ifMatch string) bool {
+	current := ma.ETag(entry)
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == current {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateEntryIfMatch is UpdateEntry guarded by optimistic locking: the stored
+// row is re-read and locked within the transaction, and when ifMatch is set
+// and no longer matches its ETag nothing is saved and a *ConflictError is
+// returned.
+func (ma *ModelAdmin) UpdateEntryIfMatch(ctx context.Context, ifMatch string, before, entry interface{}, also ...func(tx *gorm.DB) error) error {
+	return ma.updateIfMatch(ctx, ifMatch, before, entry, nil, also)
+}
+
+// PatchEntryIfMatch is UpdateEntryIfMatch writing only the given columns (and
+// the timestamps GORM maintains), e.g. those returned by ApplyPatch. Nothing
+// is written when no column changed.
+func (ma *ModelAdmin) PatchEntryIfMatch(ctx context.Context, ifMatch string, before, entry interface{}, columns []string) error {
+	if len(columns) == 0 {
+		return nil
+	}
+	s, err := ma.Schema()
+	if err != nil {
+		return err
+	}
+	for _, field := range s.Fields {
+		if field.AutoUpdateTime != 0 && field.DBName != "" {
+			columns = append(columns, field.DBName)
+		}
+	}
+	return ma.updateIfMatch(ctx, ifMatch, before, entry, columns, nil)
+}
+
+// updateIfMatch saves entry, or only columns when set, once the ETag check passed
+func (ma *ModelAdmin) updateIfMatch(ctx context.Context, ifMatch string, before, entry interface{}, columns []string, also []func(tx *gorm.DB) error) error {
+	return ma.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if ifMatch != "" {
+			current, err := ma.FindEntry(tx.Clauses(clause.Locking{Strength: "UPDATE"}), fmt.Sprint(ma.PrimaryKey(entry)))
+			if err != nil {
+				return err
+			}
+			if !ma.MatchesETag(current, ifMatch) {
+				return &ConflictError{Current: current}
+			}
+		}
+
+		var err error
+		if columns != nil {
+			err = tx.Model(entry).Select(columns).Updates(entry).Error
+		} else {
+			err = tx.Save(entry).Error
+		}
+		if err != nil {
+			return err
+		}
+		if err := ma.LogChange(tx, models.ActionUpdate, before, entry); err != nil {
+			return err
+		}
+		for _, fn := range also {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
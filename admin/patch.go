@@ -0,0 +1,452 @@
+// admin/patch.go
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Patch document formats accepted by ApplyPatch
+const (
+	MergePatch = "application/merge-patch+json" // RFC 7396
+	JSONPatch  = "application/json-patch+json"  // RFC 6902
+)
+
+// PatchError reports a malformed patch document
+type PatchError struct {
+	Message string
+}
+
+func (e *PatchError) Error() string {
+	return e.Message
+}
+
+// ErrPatchTestFailed is returned when a JSON Patch "test" operation does not match
+var ErrPatchTestFailed = errors.New("patch test operation failed")
+
+// ApplyPatch applies a merge patch or JSON patch to the JSON representation of
+// entry. Only FormFields may change; their new values are validated and
+// assigned like form input (see SetValues). It returns the columns to update.
+func (ma *ModelAdmin) ApplyPatch(ctx context.Context, entry interface{}, format string, body []byte) ([]string, error) {
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	original, err := decodeJSON(encoded)
+	if err != nil {
+		return nil, err
+	}
+	document, _ := decodeJSON(encoded)
+
+	switch format {
+	case MergePatch:
+		patch, err := decodeJSON(body)
+		if err != nil {
+			return nil, &PatchError{Message: "invalid merge patch: " + err.Error()}
+		}
+		document = mergePatch(document, patch)
+	case JSONPatch:
+		var operations []patchOperation
+		if err := json.Unmarshal(body, &operations); err != nil {
+			return nil, &PatchError{Message: "invalid JSON patch: " + err.Error()}
+		}
+		for i, operation := range operations {
+			if document, err = operation.apply(document); err != nil {
+				return nil, fmt.Errorf("operation %d (%s %s): %w", i+1, operation.Op, operation.Path, err)
+			}
+		}
+	default:
+		return nil, &PatchError{Message: fmt.Sprintf("unsupported patch format %q", format)}
+	}
+
+	before, ok := original.(map[string]interface{})
+	after, isObject := document.(map[string]interface{})
+	if !ok || !isObject {
+		return nil, &PatchError{Message: "the patched document must remain an object"}
+	}
+
+	editable := make(map[string]Field)
+	for _, field := range ma.FormColumns() {
+		if sf, ok := ma.SchemaField(field.Name); ok && field.DBName != "" {
+			editable[jsonName(sf)] = field
+		}
+	}
+
+	values := make(map[string]string)
+	errs := FieldErrors{}
+	var columns []string
+	for _, key := range changedKeys(before, after) {
+		field, ok := editable[key]
+		if !ok {
+			errs[key] = "this field cannot be changed"
+			continue
+		}
+		raw, err := patchValue(after[key])
+		if err != nil {
+			errs[field.Label] = err.Error()
+			continue
+		}
+		values[field.DBName] = raw
+		columns = append(columns, field.DBName)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	if err := ma.SetValues(ctx, entry, values, false); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// SetJSON assigns the members of a JSON object that are FormFields to entry,
+// validated like form input (see SetValues). Members are matched to the
+// entry's JSON names case-insensitively, as when decoding into the model.
+// Other members, such as the primary key and timestamps of an entry sent back
+// whole, are ignored. Fields the object leaves out are left unchanged, except
+// when adding, where required fields must be present.
+func (ma *ModelAdmin) SetJSON(ctx context.Context, entry interface{}, body []byte, adding bool) error {
+	decoded, err := decodeJSON(body)
+	if err != nil {
+		return &PatchError{Message: "invalid JSON: " + err.Error()}
+	}
+	object, ok := decoded.(map[string]interface{})
+	if !ok {
+		return &PatchError{Message: "the body must be a JSON object"}
+	}
+
+	values := make(map[string]string)
+	errs := FieldErrors{}
+	for _, field := range ma.FormColumns() {
+		sf, ok := ma.SchemaField(field.Name)
+		if !ok || field.DBName == "" || sf.PrimaryKey {
+			continue
+		}
+		for key, value := range object {
+			if !strings.EqualFold(key, jsonName(sf)) {
+				continue
+			}
+			raw, err := patchValue(value)
+			if err != nil {
+				errs[field.Label] = err.Error()
+				break
+			}
+			values[field.DBName] = raw
+			break
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return ma.SetValues(ctx, entry, values, adding)
+}
+
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// mergePatch implements RFC 7396: objects are merged recursively, null
+// removes a member and any other value replaces the target
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = mergePatch(targetObject[key], value)
+		}
+	}
+	return targetObject
+}
+
+// changedKeys lists the members of before and after whose values differ
+func changedKeys(before, after map[string]interface{}) []string {
+	var keys []string
+	for key, value := range after {
+		if old, ok := before[key]; !ok || !reflect.DeepEqual(old, value) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// patchValue turns a JSON value into form input; removed members and null
+// become empty
+func patchValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", errors.New("expected a string, number, boolean or null")
+}
+
+// patchOperation is one RFC 6902 operation
+type patchOperation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+func (o patchOperation) value() (interface{}, error) {
+	if o.Value == nil {
+		return nil, &PatchError{Message: "missing value"}
+	}
+	return decodeJSON(*o.Value)
+}
+
+func (o patchOperation) apply(document interface{}) (interface{}, error) {
+	switch o.Op {
+	case "add":
+		value, err := o.value()
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(document, o.Path, value)
+	case "remove":
+		document, _, err := pointerRemove(document, o.Path)
+		return document, err
+	case "replace":
+		value, err := o.value()
+		if err != nil {
+			return nil, err
+		}
+		if document, _, err = pointerRemove(document, o.Path); err != nil {
+			return nil, err
+		}
+		return pointerAdd(document, o.Path, value)
+	case "move":
+		if o.Path == o.From || strings.HasPrefix(o.Path, o.From+"/") {
+			return nil, &PatchError{Message: "cannot move a value into itself"}
+		}
+		document, value, err := pointerRemove(document, o.From)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(document, o.Path, value)
+	case "copy":
+		value, err := pointerGet(document, o.From)
+		if err != nil {
+			return nil, err
+		}
+		// Copy through JSON so later operations cannot alias the source
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		copied, _ := decodeJSON(encoded)
+		return pointerAdd(document, o.Path, copied)
+	case "test":
+		expected, err := o.value()
+		if err != nil {
+			return nil, err
+		}
+		actual, err := pointerGet(document, o.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(actual, expected) {
+			return nil, ErrPatchTestFailed
+		}
+		return document, nil
+	}
+	return nil, &PatchError{Message: fmt.Sprintf("unknown operation %q", o.Op)}
+}
+
+// jsonEqual compares decoded JSON values, numbers by value
+func jsonEqual(a, b interface{}) bool {
+	if x, ok := a.(json.Number); ok {
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, errX := x.Float64()
+		fy, errY := y.Float64()
+		return errX == nil && errY == nil && fx == fy
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// splitPointer parses an RFC 6901 JSON pointer into unescaped reference tokens
+func splitPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, &PatchError{Message: fmt.Sprintf("invalid JSON pointer %q", pointer)}
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func arrayIndex(token string, length int, appending bool) (int, error) {
+	if appending && token == "-" {
+		return length, nil
+	}
+	index, err := strconv.Atoi(token)
+	limit := length - 1
+	if appending {
+		limit = length
+	}
+	if err != nil || index < 0 || index > limit || (len(token) > 1 && token[0] == '0') {
+		return 0, &PatchError{Message: fmt.Sprintf("invalid array index %q", token)}
+	}
+	return index, nil
+}
+
+func pointerGet(document interface{}, pointer string) (interface{}, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	current := document
+	for _, token := range tokens {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, &PatchError{Message: fmt.Sprintf("path %q does not exist", pointer)}
+			}
+			current = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			current = node[index]
+		default:
+			return nil, &PatchError{Message: fmt.Sprintf("path %q does not exist", pointer)}
+		}
+	}
+	return current, nil
+}
+
+// pointerAdd adds value at pointer, replacing an object member or inserting
+// into an array; the parent must exist
+func pointerAdd(document interface{}, pointer string, value interface{}) (interface{}, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := pointerGet(document, parentPointer)
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = value
+		return document, nil
+	case []interface{}:
+		index, err := arrayIndex(last, len(node), true)
+		if err != nil {
+			return nil, err
+		}
+		node = append(node, nil)
+		copy(node[index+1:], node[index:])
+		node[index] = value
+		return pointerReplaceArray(document, parentPointer, node)
+	}
+	return nil, &PatchError{Message: fmt.Sprintf("path %q does not exist", pointer)}
+}
+
+// pointerRemove removes and returns the value at pointer
+func pointerRemove(document interface{}, pointer string) (interface{}, interface{}, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, document, nil
+	}
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := pointerGet(document, parentPointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	last := tokens[len(tokens)-1]
+
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		value, ok := node[last]
+		if !ok {
+			return nil, nil, &PatchError{Message: fmt.Sprintf("path %q does not exist", pointer)}
+		}
+		delete(node, last)
+		return document, value, nil
+	case []interface{}:
+		index, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := node[index]
+		node = append(node[:index:index], node[index+1:]...)
+		document, err = pointerReplaceArray(document, parentPointer, node)
+		return document, value, err
+	}
+	return nil, nil, &PatchError{Message: fmt.Sprintf("path %q does not exist", pointer)}
+}
+
+// pointerReplaceArray stores a resized array back into its parent
+func pointerReplaceArray(document interface{}, pointer string, array []interface{}) (interface{}, error) {
+	if pointer == "" {
+		return array, nil
+	}
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := pointerGet(document, parentPointer)
+	if err != nil {
+		return nil, err
+	}
+	tokens, _ := splitPointer(pointer)
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = array
+	case []interface{}:
+		index, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = array
+	}
+	return document, nil
+}
@@ -0,0 +1,182 @@
+// admin/patch_test.go
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// patchItem has a required, an optional and a read-only field
+type patchItem struct {
+	ID       uint
+	Title    string `gorm:"not null"`
+	Subtitle *string
+	Views    int
+}
+
+func newPatchAdmin(t *testing.T) (*ModelAdmin, *patchItem) {
+	db := openTestDB(t, &patchItem{})
+	subtitle := "sub"
+	return &ModelAdmin{Model: &patchItem{}, FormFields: []string{"Title", "Subtitle"}, DB: db},
+		&patchItem{ID: 1, Title: "title", Subtitle: &subtitle, Views: 3}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name          string
+		target, patch string
+		want          string
+	}{
+		{"replaces a member", `{"a":1,"b":2}`, `{"a":3}`, `{"a":3,"b":2}`},
+		{"adds a member", `{"a":1}`, `{"b":2}`, `{"a":1,"b":2}`},
+		{"null removes a member", `{"a":1,"b":2}`, `{"a":null}`, `{"b":2}`},
+		{"null removes a missing member", `{"a":1}`, `{"b":null}`, `{"a":1}`},
+		{"null removes a nested member", `{"a":{"b":1,"c":2}}`, `{"a":{"b":null}}`, `{"a":{"c":2}}`},
+		{"objects merge into a non-object", `{"a":1}`, `{"a":{"b":null,"c":2}}`, `{"a":{"c":2}}`},
+		{"arrays replace", `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+		{"a non-object patch replaces the target", `{"a":1}`, `[1]`, `[1]`},
+	}
+
+	for _, tt := range tests {
+		target, _ := decodeJSON([]byte(tt.target))
+		patch, _ := decodeJSON([]byte(tt.patch))
+		want, _ := decodeJSON([]byte(tt.want))
+		if got := mergePatch(target, patch); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestJSONPatchOperations(t *testing.T) {
+	tests := []struct {
+		name       string
+		operations string
+		want       string
+		err        error // matched with errors.Is, or any PatchError when nil and want is empty
+	}{
+		{"test passes", `[{"op":"test","path":"/a","value":1.0}]`, `{"a":1,"b":[1,2]}`, nil},
+		{"test fails", `[{"op":"test","path":"/a","value":2}]`, "", ErrPatchTestFailed},
+		{"test of a missing path", `[{"op":"test","path":"/z","value":1}]`, "", nil},
+		{"test without a value", `[{"op":"test","path":"/a"}]`, "", nil},
+		{"remove", `[{"op":"remove","path":"/b/0"}]`, `{"a":1,"b":[2]}`, nil},
+		{"remove a missing member", `[{"op":"remove","path":"/z"}]`, "", nil},
+		{"remove past the end of an array", `[{"op":"remove","path":"/b/2"}]`, "", nil},
+		{"move", `[{"op":"move","from":"/a","path":"/b/-"}]`, `{"b":[1,2,1]}`, nil},
+		{"move a missing member", `[{"op":"move","from":"/z","path":"/c"}]`, "", nil},
+		{"move into itself", `[{"op":"move","from":"/b","path":"/b/0"}]`, "", nil},
+		{"move under a missing parent", `[{"op":"move","from":"/a","path":"/z/c"}]`, "", nil},
+		{"unknown operation", `[{"op":"swap","path":"/a"}]`, "", nil},
+	}
+
+	for _, tt := range tests {
+		document, _ := decodeJSON([]byte(`{"a":1,"b":[1,2]}`))
+		var operations []patchOperation
+		if err := json.Unmarshal([]byte(tt.operations), &operations); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		var err error
+		for _, operation := range operations {
+			if document, err = operation.apply(document); err != nil {
+				break
+			}
+		}
+
+		switch {
+		case tt.want != "":
+			want, _ := decodeJSON([]byte(tt.want))
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			} else if !reflect.DeepEqual(document, want) {
+				t.Errorf("%s: got %v, want %v", tt.name, document, want)
+			}
+		case tt.err != nil:
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+			}
+		default:
+			var patchErr *PatchError
+			if !errors.As(err, &patchErr) {
+				t.Errorf("%s: got %v, want a PatchError", tt.name, err)
+			}
+		}
+	}
+}
+
+func TestChangedKeys(t *testing.T) {
+	before, _ := decodeJSON([]byte(`{"a":1,"b":{"c":2},"d":3}`))
+	after, _ := decodeJSON([]byte(`{"a":1,"b":{"c":3},"e":4}`))
+	got := changedKeys(before.(map[string]interface{}), after.(map[string]interface{}))
+	sort.Strings(got)
+	if want := []string{"b", "d", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		body    string
+		columns []string
+		err     string // "patch" for a PatchError, "fields" for FieldErrors, "test" for ErrPatchTestFailed
+	}{
+		{"merge patch", MergePatch, `{"Title":"new"}`, []string{"title"}, ""},
+		{"merge patch null clears an optional field", MergePatch, `{"Subtitle":null}`, []string{"subtitle"}, ""},
+		{"merge patch null of a required field", MergePatch, `{"Title":null}`, nil, "fields"},
+		{"merge patch of a read-only field", MergePatch, `{"Views":4}`, nil, "fields"},
+		{"merge patch replacing the document", MergePatch, `[]`, nil, "patch"},
+		{"JSON patch", JSONPatch, `[{"op":"test","path":"/Title","value":"title"},{"op":"replace","path":"/Title","value":"new"}]`, []string{"title"}, ""},
+		{"JSON patch remove of an optional field", JSONPatch, `[{"op":"remove","path":"/Subtitle"}]`, []string{"subtitle"}, ""},
+		{"JSON patch move of a read-only field", JSONPatch, `[{"op":"move","from":"/Views","path":"/Subtitle"}]`, nil, "fields"},
+		{"JSON patch failed test", JSONPatch, `[{"op":"test","path":"/Title","value":"other"},{"op":"replace","path":"/Title","value":"new"}]`, nil, "test"},
+		{"unsupported format", "application/json", `{}`, nil, "patch"},
+	}
+
+	for _, tt := range tests {
+		ma, entry := newPatchAdmin(t)
+		columns, err := ma.ApplyPatch(context.Background(), entry, tt.format, []byte(tt.body))
+
+		var (
+			patchErr    *PatchError
+			fieldErrors FieldErrors
+		)
+		switch tt.err {
+		case "":
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			} else if !reflect.DeepEqual(columns, tt.columns) {
+				t.Errorf("%s: got columns %v, want %v", tt.name, columns, tt.columns)
+			}
+		case "patch":
+			if !errors.As(err, &patchErr) {
+				t.Errorf("%s: got %v, want a PatchError", tt.name, err)
+			}
+		case "fields":
+			if !errors.As(err, &fieldErrors) {
+				t.Errorf("%s: got %v, want FieldErrors", tt.name, err)
+			}
+		case "test":
+			if !errors.Is(err, ErrPatchTestFailed) {
+				t.Errorf("%s: got %v, want ErrPatchTestFailed", tt.name, err)
+			}
+		}
+		if tt.err != "" && entry.Title != "title" {
+			t.Errorf("%s: the entry changed despite the error", tt.name)
+		}
+	}
+}
+
+func TestApplyPatchAssignsValues(t *testing.T) {
+	ma, entry := newPatchAdmin(t)
+	if _, err := ma.ApplyPatch(context.Background(), entry, MergePatch, []byte(`{"Title":"new","Subtitle":null}`)); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Title != "new" || entry.Subtitle != nil {
+		t.Errorf("got Title %q and Subtitle %v, want \"new\" and nil", entry.Title, entry.Subtitle)
+	}
+}
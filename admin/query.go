@@ -0,0 +1,266 @@
+// admin/query.go
+package admin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Query parameters understood by list endpoints; every other parameter naming
+// a FilterFields column (by its database name) filters on equality
+const (
+	ParamSearch   = "q"
+	ParamOrdering = "o"
+	ParamPage     = "page"
+	ParamPerPage  = "per_page"
+	ParamDeleted  = "deleted" // include or only, for models with soft deletes
+	ParamCursor   = "cursor"  // keyset pagination; present but empty for the first page
+	ParamCount    = "count"   // false skips counting the matching rows
+)
+
+const (
+	DefaultPerPage = 10
+	MaxPerPage     = 100 // default of ModelAdmin.MaxPerPage
+)
+
+// ListParams holds the search, filter, ordering and pagination of a list request
+type ListParams struct {
+	Search   string
+	Filters  map[string]string // database column -> value
+	Ordering string            // database column, prefixed with "-" for descending
+	Page     int
+	PerPage  int
+	Deleted  string // DeletedExclude, DeletedInclude or DeletedOnly
+
+	Keyset    bool   // paginate by Cursor instead of Page
+	Cursor    string // opaque position returned by a previous page
+	SkipCount bool
+}
+
+// ParseListParams reads list parameters from a query string map, keeping only
+// filters on FilterFields
+func (ma *ModelAdmin) ParseListParams(query map[string]string) ListParams {
+	params := ListParams{
+		Search:   strings.TrimSpace(query[ParamSearch]),
+		Filters:  make(map[string]string),
+		Ordering: query[ParamOrdering],
+		Deleted:  query[ParamDeleted],
+		Page:     1,
+		PerPage:  DefaultPerPage,
+		Cursor:   query[ParamCursor],
+	}
+	_, params.Keyset = query[ParamCursor]
+	if count, err := strconv.ParseBool(query[ParamCount]); err == nil {
+		params.SkipCount = !count
+	}
+
+	if page, err := strconv.Atoi(query[ParamPage]); err == nil && page > 0 {
+		params.Page = page
+	}
+	if perPage, err := strconv.Atoi(query[ParamPerPage]); err == nil && perPage > 0 {
+		params.PerPage = perPage
+	}
+	if params.PerPage > ma.maxPerPage() {
+		params.PerPage = ma.maxPerPage()
+	}
+
+	for _, name := range ma.FilterFields {
+		field, ok := ma.SchemaField(name)
+		if !ok {
+			continue
+		}
+		if value, ok := query[field.DBName]; ok && value != "" {
+			params.Filters[field.DBName] = value
+		}
+	}
+
+	return params
+}
+
+// Offset returns the number of rows to skip for the current page
+func (p ListParams) Offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+// maxPerPage returns MaxPerPage of the model, or the package default
+func (ma *ModelAdmin) maxPerPage() int {
+	if ma.MaxPerPage > 0 {
+		return ma.MaxPerPage
+	}
+	return MaxPerPage
+}
+
+// ApplyFilters adds the trash, search, filter and ordering clauses to query. Searching
+// matches any SearchFields column with LIKE; ordering is limited to OrderFields
+// and falls back to descending primary key.
+func (ma *ModelAdmin) ApplyFilters(query *gorm.DB, params ListParams) (*gorm.DB, error) {
+	query, err := ma.Filter(query, params)
+	if err != nil {
+		return nil, err
+	}
+	return ma.Order(query, params, false), nil
+}
+
+// Filter adds the trash, search and filter clauses of ApplyFilters, without ordering
+func (ma *ModelAdmin) Filter(query *gorm.DB, params ListParams) (*gorm.DB, error) {
+	s, err := ma.Schema()
+	if err != nil {
+		return nil, err
+	}
+
+	switch params.Deleted {
+	case DeletedExclude, DeletedInclude, DeletedOnly:
+		query = ma.applyDeleted(query, params.Deleted)
+	default:
+		return nil, fmt.Errorf("invalid value for %s: %q", ParamDeleted, params.Deleted)
+	}
+
+	if params.Search != "" {
+		var conditions []clause.Expression
+		pattern := "%" + params.Search + "%"
+		for _, name := range ma.SearchFields {
+			if field, ok := s.FieldsByName[name]; ok && field.DBName != "" {
+				conditions = append(conditions, clause.Like{
+					Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName},
+					Value:  pattern,
+				})
+			}
+		}
+		if len(conditions) > 0 {
+			query = query.Where(clause.Or(conditions...))
+		}
+	}
+
+	columns := make([]string, 0, len(params.Filters))
+	for column := range params.Filters {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		field, ok := s.FieldsByDBName[column]
+		if !ok {
+			return nil, fmt.Errorf("cannot filter on %s", column)
+		}
+		value, err := filterValue(field, params.Filters[column])
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: column},
+			Value:  value,
+		})
+	}
+
+	return query, nil
+}
+
+// Order adds the ordering of params, reversed when reverse is set, followed by
+// the primary key to keep the order stable when the column has duplicates
+func (ma *ModelAdmin) Order(query *gorm.DB, params ListParams, reverse bool) *gorm.DB {
+	orderColumn, desc := ma.orderColumn(params.Ordering)
+	query = query.Order(clause.OrderByColumn{
+		Column: clause.Column{Table: clause.CurrentTable, Name: orderColumn},
+		Desc:   desc != reverse,
+	})
+	if pk := ma.primaryColumn(); orderColumn != pk {
+		query = query.Order(clause.OrderByColumn{
+			Column: clause.Column{Table: clause.CurrentTable, Name: pk},
+			Desc:   desc != reverse,
+		})
+	}
+	return query
+}
+
+// primaryColumn returns the column of the primary key
+func (ma *ModelAdmin) primaryColumn() string {
+	s, err := ma.Schema()
+	if err != nil || s.PrioritizedPrimaryField == nil {
+		return "id"
+	}
+	return s.PrioritizedPrimaryField.DBName
+}
+
+// orderColumn validates the ordering parameter against OrderFields
+func (ma *ModelAdmin) orderColumn(ordering string) (string, bool) {
+	desc := strings.HasPrefix(ordering, "-")
+	column := strings.TrimPrefix(ordering, "-")
+
+	for _, name := range ma.OrderFields {
+		if field, ok := ma.SchemaField(name); ok && field.DBName == column {
+			return column, desc
+		}
+	}
+
+	return ma.primaryColumn(), true
+}
+
+// filterValue converts a query string value to the column type
+func filterValue(field *schema.Field, raw string) (interface{}, error) {
+	switch field.DataType {
+	case schema.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %q", field.DBName, raw)
+		}
+		return b, nil
+	case schema.Int:
+		i, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %q", field.DBName, raw)
+		}
+		return i, nil
+	case schema.Uint:
+		u, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %q", field.DBName, raw)
+		}
+		return u, nil
+	}
+	return raw, nil
+}
+
+// FilterChoice is one option of a list filter
+type FilterChoice struct {
+	Label string
+	Value string
+}
+
+// FilterOptions lists the choices offered for a FilterFields entry: yes/no for
+// booleans, the configured Choices, otherwise the distinct values currently
+// stored (at most 50)
+func (ma *ModelAdmin) FilterOptions(db *gorm.DB, name string) (Field, []FilterChoice, error) {
+	field, ok := ma.SchemaField(name)
+	if !ok {
+		return Field{}, nil, fmt.Errorf("unknown field %s", name)
+	}
+	described := ma.describe([]string{name})[0]
+
+	if len(described.Choices) > 0 {
+		choices := make([]FilterChoice, 0, len(described.Choices))
+		for _, choice := range described.Choices {
+			choices = append(choices, FilterChoice{Label: choice.Label, Value: fmt.Sprint(choice.ID)})
+		}
+		return described, choices, nil
+	}
+
+	if field.DataType == schema.Bool {
+		return described, []FilterChoice{{Label: "Yes", Value: "true"}, {Label: "No", Value: "false"}}, nil
+	}
+
+	var values []string
+	err := db.Model(ma.Model).Distinct(field.DBName).Order(field.DBName).Limit(50).Pluck(field.DBName, &values).Error
+	if err != nil {
+		return described, nil, err
+	}
+	choices := make([]FilterChoice, 0, len(values))
+	for _, value := range values {
+		choices = append(choices, FilterChoice{Label: value, Value: value})
+	}
+	return described, choices, nil
+}
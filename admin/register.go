@@ -3,20 +3,88 @@ package admin
 import (
 	"github.com/mviner000/eyygo/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var Site *AdminSite
 
+// NoteStatusChoices are the lifecycle states offered for Note.Status
+var NoteStatusChoices = []Choice{
+	{ID: models.NoteDraft, Label: "Draft"},
+	{ID: models.NoteScheduled, Label: "Scheduled"},
+	{ID: models.NotePublished, Label: "Published"},
+	{ID: models.NoteArchived, Label: "Archived"},
+}
+
 func InitializeAdmin(db *gorm.DB) {
 	Site = NewAdminSite(db)
 
 	// Register models
 	Site.Register(&models.Note{}, &ModelAdmin{
-		ListFields:   []string{"ID", "Title", "Author", "IsPublished", "CreatedAt"},
-		SearchFields: []string{"Title", "Content"},
-		FilterFields: []string{"IsPublished", "AuthorID"},
-		OrderFields:  []string{"CreatedAt", "Title"},
-		FormFields:   []string{"Title", "Content", "AuthorID", "IsPublished", "Tags"},
+		ListFields:     []string{"ID", "Title", "Author", "Status", "PublishAt", "CreatedAt"},
+		SearchFields:   []string{"Title", "Content"},
+		FilterFields:   []string{"Status", "AuthorID"},
+		OrderFields:    []string{"CreatedAt", "PublishAt", "Title"},
+		FormFields:     []string{"Title", "Content", "AuthorID", "Status", "PublishAt"},
+		Choices:        map[string][]Choice{"Status": NoteStatusChoices},
+		LabelField:     "Title",
+		MarkdownFields: []string{"Content"},
+		Inlines:        []string{"Shares"},
+		Actions: []Action{
+			noteStatusAction("publish", "Publish selected notes", models.NotePublished),
+			noteStatusAction("unpublish", "Return selected notes to draft", models.NoteDraft),
+			noteStatusAction("archive", "Archive selected notes", models.NoteArchived),
+		},
+		DB: db,
+	})
+
+	Site.Register(&models.Tag{}, &ModelAdmin{
+		ListFields:   []string{"ID", "Name", "Slug", "CreatedAt"},
+		SearchFields: []string{"Name", "Slug"},
+		OrderFields:  []string{"Name", "CreatedAt"},
+		FormFields:   []string{"Name"},
+		LabelField:   "Name",
+		DB:           db,
+	})
+
+	Site.Register(&models.NoteShare{}, &ModelAdmin{
+		ListFields:   []string{"ID", "Note", "User", "Group", "Permission", "CreatedAt"},
+		FilterFields: []string{"Permission", "NoteID", "UserID", "GroupID"},
+		OrderFields:  []string{"CreatedAt"},
+		FormFields:   []string{"NoteID", "UserID", "GroupID", "Permission"},
+		Choices: map[string][]Choice{
+			"Permission": {
+				{ID: models.ShareViewer, Label: "Viewer"},
+				{ID: models.ShareEditor, Label: "Editor"},
+			},
+		},
+		DB: db,
+	})
+
+	// Links are created through the API, which is the only place showing
+	// their token; the admin lists and revokes them
+	Site.Register(&models.ShareLink{}, &ModelAdmin{
+		ListFields:   []string{"ID", "Note", "ExpiresAt", "CreatedBy", "CreatedAt"},
+		FilterFields: []string{"NoteID"},
+		OrderFields:  []string{"CreatedAt", "ExpiresAt"},
+		FormFields:   []string{"ExpiresAt"},
+		DB:           db,
+	})
+
+	Site.Register(&models.Group{}, &ModelAdmin{
+		ListFields:   []string{"ID", "Name", "CreatedAt"},
+		SearchFields: []string{"Name"},
+		OrderFields:  []string{"Name", "CreatedAt"},
+		FormFields:   []string{"Name"},
+		LabelField:   "Name",
+		Inlines:      []string{"Members"},
+		DB:           db,
+	})
+
+	Site.Register(&models.GroupMember{}, &ModelAdmin{
+		ListFields:   []string{"ID", "Group", "User", "CreatedAt"},
+		FilterFields: []string{"GroupID", "UserID"},
+		FormFields:   []string{"GroupID", "UserID"},
 		DB:           db,
 	})
 
@@ -26,6 +94,32 @@ func InitializeAdmin(db *gorm.DB) {
 		FilterFields: []string{"IsActive", "IsStaff", "IsSuperUser"},
 		OrderFields:  []string{"Username", "DateJoined"},
 		FormFields:   []string{"Username", "Email", "Password", "FirstName", "LastName", "IsActive", "IsStaff"},
-		DB:           db,
+		ImportKey:    "Username",
+		LabelField:   "Username",
+		Inlines:      []string{"Notes"},
+		Actions: []Action{
+			BooleanAction("activate", "Activate selected users", "IsActive", true),
+			BooleanAction("deactivate", "Deactivate selected users", "IsActive", false),
+		},
+		DB: db,
 	})
 }
+
+// noteStatusAction builds an action moving the selected notes to a status.
+// Notes are saved whole so that their lifecycle timestamps follow.
+func noteStatusAction(name, label, status string) Action {
+	return Action{
+		Name:  name,
+		Label: label,
+		Func: func(ma *ModelAdmin, tx *gorm.DB, ids []string) (*ActionResult, error) {
+			return ma.EachSelected(tx, ids, func(tx *gorm.DB, entry interface{}) error {
+				before := ma.Snapshot(entry)
+				entry.(*models.Note).Status = status
+				if err := tx.Omit(clause.Associations).Save(entry).Error; err != nil {
+					return err
+				}
+				return ma.LogChange(tx, models.ActionUpdate, before, entry)
+			})
+		},
+	}
+}
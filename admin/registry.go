@@ -3,9 +3,12 @@ package admin
 
 import (
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 )
 
 // ModelAdmin defines the configuration and customization for a model in the admin interface
@@ -16,7 +19,47 @@ type ModelAdmin struct {
 	FilterFields []string
 	OrderFields  []string
 	FormFields   []string
-	DB           *gorm.DB
+	DB           *gorm.DB `json:"-"`
+
+	// LabelField shows an entry where it is referenced, e.g. "Username" for
+	// the author of a note
+	LabelField string
+
+	// Preload lists relations to load with every entry, in addition to the
+	// relations in ListFields
+	Preload []string
+
+	// Inlines lists has-many relations whose children are edited on this
+	// model's form, e.g. "Notes" on User
+	Inlines []string
+
+	// MaxPerPage caps the page size clients may request; the package
+	// default (100) when zero
+	MaxPerPage int
+
+	// Choices restricts fields to fixed values, e.g. the statuses of a note;
+	// forms offer them in a select and reject anything else
+	Choices map[string][]Choice
+
+	// MarkdownFields are text fields written in Markdown; forms show a
+	// preview of them rendered
+	MarkdownFields []string
+
+	// ImportKey is the field imported rows are matched on, e.g. "Username";
+	// the primary key when empty
+	ImportKey string
+
+	// Actions are bulk operations offered on selected entries, in addition
+	// to the built-in "delete_selected"
+	Actions []Action
+
+	// Name is the lowercase registry key, e.g. "note"
+	Name string
+
+	site       *AdminSite
+	schemaOnce sync.Once
+	schema     *schema.Schema
+	schemaErr  error
 }
 
 // AdminSite handles the registration and management of models
@@ -35,26 +78,29 @@ func NewAdminSite(db *gorm.DB) *AdminSite {
 
 // Register adds a model to the admin interface
 func (site *AdminSite) Register(model interface{}, config *ModelAdmin) {
-	modelType := reflect.TypeOf(model)
+	modelType := reflect.Indirect(reflect.ValueOf(model)).Type()
 	modelName := strings.ToLower(modelType.Name())
 
 	if config == nil {
-		config = &ModelAdmin{
-			Model: model,
-			DB:    site.db,
-		}
+		config = &ModelAdmin{}
 
 		// Auto-generate fields if not specified
-		val := reflect.ValueOf(model)
-		typ := val.Type()
-		for i := 0; i < typ.NumField(); i++ {
-			field := typ.Field(i)
+		for i := 0; i < modelType.NumField(); i++ {
+			field := modelType.Field(i)
 			if field.Name != "Model" && !strings.HasSuffix(field.Name, "At") {
 				config.ListFields = append(config.ListFields, field.Name)
 				config.FormFields = append(config.FormFields, field.Name)
 			}
 		}
 	}
+	if config.Model == nil {
+		config.Model = model
+	}
+	if config.DB == nil {
+		config.DB = site.db
+	}
+	config.Name = modelName
+	config.site = site
 
 	site.registry[modelName] = config
 }
@@ -69,3 +115,13 @@ func (site *AdminSite) GetModelAdmin(modelName string) (*ModelAdmin, bool) {
 func (site *AdminSite) GetRegisteredModels() map[string]*ModelAdmin {
 	return site.registry
 }
+
+// GetModelNames returns the names of all registered models, sorted
+func (site *AdminSite) GetModelNames() []string {
+	names := make([]string, 0, len(site.registry))
+	for name := range site.registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
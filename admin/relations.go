@@ -0,0 +1,340 @@
+// admin/relations.go
+package admin
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/mviner000/eyygo/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// InputRelation is the form input of a belongs-to foreign key, e.g. AuthorID
+const InputRelation = "relation"
+
+// DefaultAutocompleteLimit is the number of choices returned by Autocomplete
+const DefaultAutocompleteLimit = 20
+
+// Choice is a related object offered by autocomplete and relation inputs
+type Choice struct {
+	ID    interface{} `json:"id"`
+	Label string      `json:"label"`
+}
+
+// Inline is a has-many relation whose children are edited on the parent's form
+type Inline struct {
+	Name       string      // relation field on the parent, e.g. "Notes"
+	Label      string      // e.g. "Notes"
+	Admin      *ModelAdmin // admin of the child model
+	ForeignKey Field       // child field pointing at the parent, e.g. AuthorID
+	Fields     []Field     // child FormFields, without the foreign key
+
+	owner *ModelAdmin
+}
+
+// related returns the admin registered for the model of a relationship
+func (ma *ModelAdmin) related(relationship *schema.Relationship) (*ModelAdmin, bool) {
+	if ma.site == nil {
+		return nil, false
+	}
+	related, ok := ma.site.GetModelAdmin(strings.ToLower(relationship.FieldSchema.Name))
+	return related, ok
+}
+
+// belongsTo returns the belongs-to relationship of a relation field (Author)
+// or of its foreign key field (AuthorID)
+func (ma *ModelAdmin) belongsTo(name string) (*schema.Relationship, bool) {
+	s, err := ma.Schema()
+	if err != nil {
+		return nil, false
+	}
+	for _, relationship := range s.Relationships.BelongsTo {
+		if relationship.Name == name {
+			return relationship, true
+		}
+		for _, reference := range relationship.References {
+			if reference.ForeignKey != nil && reference.ForeignKey.Name == name {
+				return relationship, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// ObjectLabel returns how an entry is shown when it is referenced: its
+// LabelField, or "<Model> <id>"
+func (ma *ModelAdmin) ObjectLabel(entry interface{}) string {
+	if ma.LabelField != "" {
+		if label := FormatValue(FieldValue(entry, ma.LabelField), ""); label != "" {
+			return label
+		}
+	}
+	return fmt.Sprintf("%s %v", Label(ma.Name), ma.PrimaryKey(entry))
+}
+
+// Display renders a list column of entry; relations and choices are shown by their label
+func (ma *ModelAdmin) Display(entry interface{}, field Field) string {
+	value := FieldValue(entry, field.Name)
+	for _, choice := range ma.Choices[field.Name] {
+		if fmt.Sprint(choice.ID) == fmt.Sprint(value) {
+			return choice.Label
+		}
+	}
+	if relationship, ok := ma.belongsTo(field.Name); ok && relationship.Field.Name == field.Name {
+		related := reflect.ValueOf(value)
+		if !related.IsValid() || (related.Kind() == reflect.Ptr && related.IsNil()) {
+			return ""
+		}
+		if relatedAdmin, ok := ma.related(relationship); ok {
+			return relatedAdmin.ObjectLabel(value)
+		}
+	}
+	return FormatValue(value, "")
+}
+
+// Preloaded adds the preloads of Preload and of the relations in ListFields.
+// Belongs-to relations of registered models only load their key and label.
+func (ma *ModelAdmin) Preloaded(query *gorm.DB) *gorm.DB {
+	for _, name := range ma.preloads() {
+		relationship, ok := ma.belongsTo(name)
+		if !ok || relationship.Field.Name != name {
+			query = query.Preload(name)
+			continue
+		}
+		relatedAdmin, ok := ma.related(relationship)
+		if !ok {
+			query = query.Preload(name)
+			continue
+		}
+		columns := relatedAdmin.labelColumns()
+		query = query.Preload(name, func(db *gorm.DB) *gorm.DB {
+			return db.Select(columns)
+		})
+	}
+	return query
+}
+
+// preloads lists Preload followed by the belongs-to relations in ListFields
+func (ma *ModelAdmin) preloads() []string {
+	names := append([]string(nil), ma.Preload...)
+	for _, name := range ma.ListFields {
+		if relationship, ok := ma.belongsTo(name); ok && relationship.Field.Name == name && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// labelColumns are the columns needed to show an entry by ObjectLabel
+func (ma *ModelAdmin) labelColumns() []string {
+	var columns []string
+	s, err := ma.Schema()
+	if err == nil && s.PrioritizedPrimaryField != nil {
+		columns = append(columns, s.PrioritizedPrimaryField.DBName)
+	}
+	if field, ok := ma.SchemaField(ma.LabelField); ok && field.DBName != "" {
+		columns = append(columns, field.DBName)
+	}
+	return columns
+}
+
+// Autocomplete returns up to limit entries whose SearchFields (or LabelField)
+// contain q, for picking related objects
+func (ma *ModelAdmin) Autocomplete(db *gorm.DB, q string, limit int) ([]Choice, error) {
+	if limit <= 0 || limit > ma.maxPerPage() {
+		limit = DefaultAutocompleteLimit
+	}
+
+	query := db.Model(ma.Model)
+	if q = strings.TrimSpace(q); q != "" {
+		searchFields := ma.SearchFields
+		if len(searchFields) == 0 && ma.LabelField != "" {
+			searchFields = []string{ma.LabelField}
+		}
+		var conditions []clause.Expression
+		for _, name := range searchFields {
+			if field, ok := ma.SchemaField(name); ok && field.DBName != "" {
+				conditions = append(conditions, clause.Like{
+					Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName},
+					Value:  "%" + q + "%",
+				})
+			}
+		}
+		if len(conditions) == 0 {
+			return nil, fmt.Errorf("%s cannot be searched", ma.Name)
+		}
+		query = query.Where(clause.Or(conditions...))
+	}
+
+	if field, ok := ma.SchemaField(ma.LabelField); ok && field.DBName != "" {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}})
+	}
+
+	entries := ma.NewSlice()
+	if err := query.Limit(limit).Find(entries).Error; err != nil {
+		return nil, err
+	}
+
+	choices := []Choice{}
+	for _, entry := range entriesOf(entries) {
+		choices = append(choices, Choice{ID: ma.PrimaryKey(entry), Label: ma.ObjectLabel(entry)})
+	}
+	return choices, nil
+}
+
+// RelatedModel returns the admin of the model a foreign key field points to
+func (ma *ModelAdmin) RelatedModel(field Field) (*ModelAdmin, bool) {
+	relationship, ok := ma.belongsTo(field.Name)
+	if !ok || relationship.Field.Name == field.Name {
+		return nil, false
+	}
+	return ma.related(relationship)
+}
+
+// RelatedChoice returns the choice currently selected by a foreign key field of entry
+func (ma *ModelAdmin) RelatedChoice(db *gorm.DB, entry interface{}, field Field) (Choice, bool) {
+	relatedAdmin, ok := ma.RelatedModel(field)
+	if !ok {
+		return Choice{}, false
+	}
+	id := FieldValue(entry, field.Name)
+	if id == nil || reflect.ValueOf(id).IsZero() {
+		return Choice{}, false
+	}
+	related, err := relatedAdmin.FindEntry(db, fmt.Sprint(exportValue(id)))
+	if err != nil {
+		return Choice{ID: id, Label: fmt.Sprint(exportValue(id))}, true
+	}
+	return Choice{ID: relatedAdmin.PrimaryKey(related), Label: relatedAdmin.ObjectLabel(related)}, true
+}
+
+// GetInlines describes the has-many relations listed in Inlines
+func (ma *ModelAdmin) GetInlines() []Inline {
+	s, err := ma.Schema()
+	if err != nil {
+		return nil
+	}
+
+	var inlines []Inline
+	for _, name := range ma.Inlines {
+		relationship, ok := s.Relationships.Relations[name]
+		if !ok || relationship.Type != schema.HasMany || len(relationship.References) != 1 {
+			continue
+		}
+		childAdmin, ok := ma.related(relationship)
+		if !ok {
+			continue
+		}
+
+		foreignKey := relationship.References[0].ForeignKey
+		inline := Inline{
+			Name:       name,
+			Label:      Label(name),
+			Admin:      childAdmin,
+			ForeignKey: childAdmin.describe([]string{foreignKey.Name})[0],
+			owner:      ma,
+		}
+		for _, field := range childAdmin.FormColumns() {
+			if field.Name != foreignKey.Name {
+				inline.Fields = append(inline.Fields, field)
+			}
+		}
+		inlines = append(inlines, inline)
+	}
+	return inlines
+}
+
+// Children returns the child entries of parent
+func (in Inline) Children(db *gorm.DB, parent interface{}) ([]interface{}, error) {
+	entries := in.Admin.NewSlice()
+	err := db.Model(in.Admin.Model).Where(clause.Eq{
+		Column: clause.Column{Table: clause.CurrentTable, Name: in.ForeignKey.DBName},
+		Value:  in.owner.PrimaryKey(parent),
+	}).Order(clause.OrderByColumn{Column: clause.PrimaryColumn}).Find(entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entriesOf(entries), nil
+}
+
+// InlineRow is the submitted state of one child row. Rows without an ID are
+// new; they are skipped when every value is empty.
+type InlineRow struct {
+	ID     string
+	Values map[string]string
+	Delete bool
+}
+
+// Save applies the submitted rows to the children of parent within tx and
+// records every change in the audit log
+func (in Inline) Save(tx *gorm.DB, parent interface{}, rows []InlineRow) error {
+	parentID := in.owner.PrimaryKey(parent)
+	for i, row := range rows {
+		if err := in.saveRow(tx, parentID, row); err != nil {
+			return fmt.Errorf("%s row %d: %w", in.Label, i+1, err)
+		}
+	}
+	return nil
+}
+
+func (in Inline) saveRow(tx *gorm.DB, parentID interface{}, row InlineRow) error {
+	child := in.Admin
+
+	if row.ID == "" {
+		if row.Delete || allEmpty(row.Values) {
+			return nil
+		}
+		values := make(map[string]string, len(row.Values)+1)
+		for column, value := range row.Values {
+			values[column] = value
+		}
+		values[in.ForeignKey.DBName] = fmt.Sprint(parentID)
+
+		entry := child.NewEntry()
+		if err := child.SetValues(tx.Statement.Context, entry, values, true); err != nil {
+			return err
+		}
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+		return child.LogChange(tx, models.ActionCreate, nil, entry)
+	}
+
+	// Only children of this parent may be changed through its form
+	entry, err := child.FindEntry(tx.Where(clause.Eq{
+		Column: clause.Column{Table: clause.CurrentTable, Name: in.ForeignKey.DBName},
+		Value:  parentID,
+	}), row.ID)
+	if err != nil {
+		return err
+	}
+
+	if row.Delete {
+		if err := tx.Delete(entry).Error; err != nil {
+			return err
+		}
+		return child.LogChange(tx, models.ActionDelete, entry, nil)
+	}
+
+	before := child.Snapshot(entry)
+	if err := child.SetValues(tx.Statement.Context, entry, row.Values, false); err != nil {
+		return err
+	}
+	if err := tx.Save(entry).Error; err != nil {
+		return err
+	}
+	return child.LogChange(tx, models.ActionUpdate, before, entry)
+}
+
+func allEmpty(values map[string]string) bool {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" && value != "false" {
+			return false
+		}
+	}
+	return true
+}
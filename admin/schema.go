@@ -0,0 +1,168 @@
+// admin/schema.go
+package admin
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
+// Relation types reported by ModelSchema
+const (
+	RelationBelongsTo  = "belongs_to"
+	RelationHasOne     = "has_one"
+	RelationHasMany    = "has_many"
+	RelationManyToMany = "many_to_many"
+)
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// ModelSchema describes a registered model for clients that build lists and
+// forms generically
+type ModelSchema struct {
+	Name        string           `json:"name"`
+	Label       string           `json:"label"`
+	Table       string           `json:"table"`
+	PrimaryKey  string           `json:"primary_key"` // JSON name
+	LabelField  string           `json:"label_field,omitempty"`
+	SoftDeletes bool             `json:"soft_deletes"`
+	Fields      []SchemaField    `json:"fields"`
+	Relations   []SchemaRelation `json:"relations"`
+	Actions     []Action         `json:"actions"`
+}
+
+// SchemaField describes a stored field of a model
+type SchemaField struct {
+	Field
+	JSONName   string `json:"json_name"`
+	Type       string `json:"type"` // GORM data type, e.g. string, uint, bool, time
+	PrimaryKey bool   `json:"primary_key"`
+	Nullable   bool   `json:"nullable"`
+	Default    string `json:"default,omitempty"`
+	WriteOnly  bool   `json:"write_only"` // sensitive, never returned by exports or the log
+
+	Listable   bool `json:"listable"`
+	Searchable bool `json:"searchable"`
+	Filterable bool `json:"filterable"`
+	Orderable  bool `json:"orderable"`
+	Editable   bool `json:"editable"`
+}
+
+// SchemaRelation describes a relation of a model
+type SchemaRelation struct {
+	Name        string   `json:"name"`
+	JSONName    string   `json:"json_name"`
+	Type        string   `json:"type"`
+	Model       string   `json:"model,omitempty"`        // admin name of the related model, if registered
+	ForeignKeys []string `json:"foreign_keys,omitempty"` // Go names of the foreign key fields
+	Listable    bool     `json:"listable"`
+	Preloaded   bool     `json:"preloaded"`
+	Inline      bool     `json:"inline"`
+}
+
+// Describe returns the ModelSchema of the model, derived from GORM's schema
+// and the ModelAdmin options
+func (ma *ModelAdmin) Describe() (ModelSchema, error) {
+	s, err := ma.Schema()
+	if err != nil {
+		return ModelSchema{}, err
+	}
+
+	described := ModelSchema{
+		Name:        ma.Name,
+		Label:       Label(s.Name),
+		Table:       s.Table,
+		LabelField:  ma.LabelField,
+		SoftDeletes: ma.SoftDeletes(),
+		Fields:      []SchemaField{},
+		Relations:   []SchemaRelation{},
+		Actions:     ma.GetActions(),
+	}
+	if s.PrioritizedPrimaryField != nil {
+		described.PrimaryKey = jsonName(s.PrioritizedPrimaryField)
+	}
+
+	preloads := ma.preloads()
+	for _, sf := range s.Fields {
+		if relationship, ok := s.Relationships.Relations[sf.Name]; ok {
+			described.Relations = append(described.Relations, ma.describeRelation(relationship, slices.Contains(preloads, sf.Name)))
+			continue
+		}
+		if sf.DBName == "" || jsonName(sf) == "" {
+			continue
+		}
+		described.Fields = append(described.Fields, ma.describeField(sf))
+	}
+	return described, nil
+}
+
+func (ma *ModelAdmin) describeField(sf *schema.Field) SchemaField {
+	field := SchemaField{
+		Field:      ma.describe([]string{sf.Name})[0],
+		JSONName:   jsonName(sf),
+		Type:       string(sf.GORMDataType),
+		PrimaryKey: sf.PrimaryKey,
+		Nullable:   !sf.NotNull && !sf.PrimaryKey && nullable(sf.FieldType),
+		Default:    sf.DefaultValue,
+		WriteOnly:  IsSensitive(sf.Name),
+		Listable:   slices.Contains(ma.ListFields, sf.Name),
+		Searchable: slices.Contains(ma.SearchFields, sf.Name),
+		Filterable: slices.Contains(ma.FilterFields, sf.Name),
+		Orderable:  slices.Contains(ma.OrderFields, sf.Name),
+		Editable:   slices.Contains(ma.FormFields, sf.Name),
+	}
+	if field.Type == "" {
+		field.Type = sf.FieldType.String()
+	}
+	return field
+}
+
+func (ma *ModelAdmin) describeRelation(relationship *schema.Relationship, preloaded bool) SchemaRelation {
+	relation := SchemaRelation{
+		Name:      relationship.Name,
+		JSONName:  jsonName(relationship.Field),
+		Listable:  slices.Contains(ma.ListFields, relationship.Name),
+		Preloaded: preloaded,
+		Inline:    slices.Contains(ma.Inlines, relationship.Name),
+	}
+	switch relationship.Type {
+	case schema.BelongsTo:
+		relation.Type = RelationBelongsTo
+	case schema.HasOne:
+		relation.Type = RelationHasOne
+	case schema.HasMany:
+		relation.Type = RelationHasMany
+	case schema.Many2Many:
+		relation.Type = RelationManyToMany
+	}
+	if related, ok := ma.related(relationship); ok {
+		relation.Model = related.Name
+	}
+	for _, reference := range relationship.References {
+		if reference.ForeignKey != nil {
+			relation.ForeignKeys = append(relation.ForeignKeys, reference.ForeignKey.Name)
+		}
+	}
+	return relation
+}
+
+// jsonName returns the key of a field in the model's JSON, or "" when it is
+// not serialized
+func jsonName(sf *schema.Field) string {
+	name, _, _ := strings.Cut(sf.StructField.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return sf.Name
+	}
+	return name
+}
+
+// nullable reports whether values of a Go type can hold NULL
+func nullable(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr || t.Implements(valuerType) || reflect.PointerTo(t).Implements(valuerType)
+}
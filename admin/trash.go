@@ -0,0 +1,146 @@
+// admin/trash.go
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mviner000/eyygo/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Values of the ParamDeleted list parameter
+const (
+	DeletedExclude = ""        // default: soft-deleted entries are hidden
+	DeletedInclude = "include" // live and soft-deleted entries
+	DeletedOnly    = "only"    // the trash: soft-deleted entries only
+)
+
+var (
+	// ErrNotDeleted is returned when restoring an entry that is not soft-deleted
+	ErrNotDeleted = errors.New("entry is not deleted")
+
+	// ErrInUse is returned when purging an entry other rows still reference
+	ErrInUse = errors.New("entry is still referenced by other entries")
+)
+
+// SoftDeletes reports whether the model has a gorm.DeletedAt field
+func (ma *ModelAdmin) SoftDeletes() bool {
+	return ma.deletedAtField() != nil
+}
+
+func (ma *ModelAdmin) deletedAtField() *schema.Field {
+	s, err := ma.Schema()
+	if err != nil {
+		return nil
+	}
+	for _, field := range s.Fields {
+		if isDeletedAt(field) {
+			return field
+		}
+	}
+	return nil
+}
+
+// applyDeleted scopes query to the soft-deleted entries requested by ParamDeleted
+func (ma *ModelAdmin) applyDeleted(query *gorm.DB, deleted string) *gorm.DB {
+	field := ma.deletedAtField()
+	if field == nil || deleted == DeletedExclude {
+		return query
+	}
+
+	query = query.Unscoped()
+	if deleted == DeletedOnly {
+		query = query.Where(clause.Neq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName},
+			Value:  nil,
+		})
+	}
+	return query
+}
+
+// IsDeleted reports whether entry is soft-deleted
+func (ma *ModelAdmin) IsDeleted(entry interface{}) bool {
+	field := ma.deletedAtField()
+	if field == nil {
+		return false
+	}
+	deletedAt, _ := FieldValue(entry, field.Name).(gorm.DeletedAt)
+	return deletedAt.Valid
+}
+
+// RestoreEntry clears the deletion time of a soft-deleted entry and logs it
+func (ma *ModelAdmin) RestoreEntry(ctx context.Context, entry interface{}) error {
+	field := ma.deletedAtField()
+	if field == nil || !ma.IsDeleted(entry) {
+		return ErrNotDeleted
+	}
+
+	return ma.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(entry).Update(field.DBName, nil).Error; err != nil {
+			return err
+		}
+		return ma.LogChange(tx, models.ActionRestore, entry, entry)
+	})
+}
+
+// PurgeEntry permanently deletes an entry, soft-deleted or not, and logs it.
+// It returns ErrInUse when other rows still reference the entry.
+func (ma *ModelAdmin) PurgeEntry(ctx context.Context, entry interface{}) error {
+	return ma.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return ma.purge(tx, entry)
+	})
+}
+
+func (ma *ModelAdmin) purge(tx *gorm.DB, entry interface{}) error {
+	if err := tx.Unscoped().Delete(entry).Error; err != nil {
+		if translator, ok := tx.Dialector.(gorm.ErrorTranslator); ok && errors.Is(translator.Translate(err), gorm.ErrForeignKeyViolated) {
+			return ErrInUse
+		}
+		return err
+	}
+	return ma.LogChange(tx, models.ActionPurge, entry, nil)
+}
+
+// PurgeDeleted permanently deletes the entries soft-deleted before cutoff, in
+// batches. Each entry is purged on its own; those that cannot be, e.g. a user
+// who still has notes, are reported in the result and left in place.
+func (ma *ModelAdmin) PurgeDeleted(ctx context.Context, cutoff time.Time) (*ActionResult, error) {
+	result := &ActionResult{}
+	field := ma.deletedAtField()
+	if field == nil {
+		return result, nil
+	}
+
+	pk := clause.Column{Table: clause.CurrentTable, Name: ma.primaryColumn()}
+	var failed []interface{}
+	for {
+		query := ma.DB.WithContext(ctx).Unscoped().Model(ma.Model).
+			Where(clause.Lt{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: cutoff})
+		if len(failed) > 0 {
+			query = query.Where(clause.Not(clause.IN{Column: pk, Values: failed}))
+		}
+		entries := ma.NewSlice()
+		if err := query.Limit(100).Find(entries).Error; err != nil {
+			return result, err
+		}
+
+		batch := entriesOf(entries)
+		if len(batch) == 0 {
+			return result, nil
+		}
+		for _, entry := range batch {
+			if err := ma.PurgeEntry(ctx, entry); err != nil {
+				key := ma.PrimaryKey(entry)
+				failed = append(failed, key)
+				result.Failed = append(result.Failed, ActionFailure{ID: fmt.Sprint(key), Error: err.Error()})
+				continue
+			}
+			result.Affected++
+		}
+	}
+}
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/mviner000/eyygo/admin"
 	"github.com/mviner000/eyygo/config"
 	"github.com/mviner000/eyygo/models"
+	"github.com/mviner000/eyygo/search"
 	"github.com/mviner000/eyygo/settings"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
@@ -38,8 +43,35 @@ var createSuperUserCmd = &cobra.Command{
 	Run:   createSuperUser,
 }
 
+var purgeDeletedCmd = &cobra.Command{
+	Use:   "purge_deleted",
+	Short: "Permanently delete entries soft-deleted longer ago than --older-than",
+	Run:   purgeDeleted,
+}
+
+var rebuildSearchIndexCmd = &cobra.Command{
+	Use:   "rebuild_search_index",
+	Short: "Index every note anew for full-text search",
+	Run:   rebuildSearchIndex,
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Inspect the configuration for common problems",
+	Run:   check,
+}
+
 func init() {
+	checkCmd.Flags().Bool("deploy", false, "Treat security issues as errors, as in production")
+
 	rootCmd.AddCommand(createSuperUserCmd)
+	rootCmd.AddCommand(checkCmd)
+
+	purgeDeletedCmd.Flags().String("older-than", "30d", "Minimum age of soft-deleted entries, e.g. 30d, 12h")
+	purgeDeletedCmd.Flags().String("model", "", "Only purge this admin model (default: every model with soft deletes)")
+	rootCmd.AddCommand(purgeDeletedCmd)
+
+	rootCmd.AddCommand(rebuildSearchIndexCmd)
 }
 
 func main() {
@@ -144,6 +176,135 @@ func createSuperUser(cmd *cobra.Command, args []string) {
 	fmt.Printf("Email: %s\n", cyan(email))
 }
 
+func check(cmd *cobra.Command, args []string) {
+	// Load configuration (validation errors are reported here)
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Println(red("Error loading config:"), err)
+		os.Exit(1)
+	}
+
+	deploy, _ := cmd.Flags().GetBool("deploy")
+	fatal := deploy || cfg.IsProduction()
+
+	issues := cfg.CheckSecurity()
+	if len(issues) == 0 {
+		fmt.Println(green("System check identified no issues."))
+		return
+	}
+
+	label := yellow("WARNINGS:")
+	if fatal {
+		label = red("ERRORS:")
+	}
+	fmt.Println("System check identified some issues:")
+	fmt.Println("\n" + label)
+	for _, issue := range issues {
+		fmt.Printf("?: (%s) %s\n", cyan(issue.ID), issue.Message)
+		fmt.Printf("\tHINT: %s\n", issue.Hint)
+	}
+	fmt.Printf("\nSystem check identified %d issue(s).\n", len(issues))
+
+	if fatal {
+		os.Exit(1)
+	}
+}
+
+func purgeDeleted(cmd *cobra.Command, args []string) {
+	olderThan, _ := cmd.Flags().GetString("older-than")
+	age, err := parseAge(olderThan)
+	if err != nil {
+		fmt.Println(red("Error:"), err)
+		os.Exit(1)
+	}
+	only, _ := cmd.Flags().GetString("model")
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Println(red("Error loading config:"), err)
+		os.Exit(1)
+	}
+
+	db, err := settings.NewDBConnection(cfg)
+	if err != nil {
+		fmt.Println(red("Error connecting to database:"), err)
+		os.Exit(1)
+	}
+	admin.InitializeAdmin(db)
+
+	names := admin.Site.GetModelNames()
+	if only != "" {
+		if _, exists := admin.Site.GetModelAdmin(only); !exists {
+			fmt.Println(red("Error: unknown model"), only)
+			os.Exit(1)
+		}
+		names = []string{only}
+	}
+
+	// Timestamps are stored in UTC, see settings.openDatabase
+	cutoff := time.Now().UTC().Add(-age)
+	fmt.Printf("Purging entries deleted before %s\n", cyan(cutoff.Format("2006-01-02 15:04:05")))
+	for _, name := range names {
+		modelAdmin, _ := admin.Site.GetModelAdmin(name)
+		if !modelAdmin.SoftDeletes() {
+			continue
+		}
+		result, err := modelAdmin.PurgeDeleted(context.Background(), cutoff)
+		if err != nil {
+			fmt.Println(red("Error purging "+name+":"), err)
+			os.Exit(1)
+		}
+		fmt.Printf("  %s: %s purged\n", name, green(result.Affected))
+		for _, failure := range result.Failed {
+			fmt.Printf("    %s %s: %s\n", red("not purged"), failure.ID, failure.Error)
+		}
+	}
+}
+
+func rebuildSearchIndex(cmd *cobra.Command, args []string) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Println(red("Error loading config:"), err)
+		os.Exit(1)
+	}
+
+	db, err := settings.NewDBConnection(cfg)
+	if err != nil {
+		fmt.Println(red("Error connecting to database:"), err)
+		os.Exit(1)
+	}
+
+	backend, err := search.Setup(db)
+	if err != nil {
+		fmt.Println(red("Error setting up full-text search:"), err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Rebuilding the %s search index\n", cyan(backend.Name()))
+	indexed, err := backend.Rebuild(db)
+	if err != nil {
+		fmt.Println(red("Error rebuilding the search index:"), err)
+		os.Exit(1)
+	}
+	fmt.Printf("  %s notes indexed\n", green(indexed))
+}
+
+// parseAge parses a duration that may also be given in days, e.g. "30d"
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	age, err := time.ParseDuration(value)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return age, nil
+}
+
 func promptString(prompt string) string {
 	fmt.Printf("%s: ", prompt)
 	var value string
@@ -2,54 +2,235 @@ package config
 
 import (
 	"fmt"
-	"os"
+	"strings"
+	"time"
+)
+
+// DefaultDatabase is the name of the database configured by the DB_* settings
+const DefaultDatabase = "default"
 
-	"github.com/joho/godotenv"
+// Profiles selected through EYYGO_ENV or --env
+const (
+	EnvDevelopment = "development"
+	EnvTest        = "test"
+	EnvProduction  = "production"
 )
 
+// Config holds every setting of the application. Each field is tagged with
+// its key in config files (`config`) and its environment variable (`env`);
+// the matching command line flag is the file key with dots replaced by dashes.
 type Config struct {
-	// Server settings
-	ServerPort string
-	ServerHost string
-
-	// Database settings
-	DBDriver   string
-	DBHost     string
-	DBPort     string
-	DBUser     string
-	DBPassword string
-	DBName     string
-	DBSSLMode  string
-}
-
-func LoadConfig() (*Config, error) {
-	err := godotenv.Load()
-	if err != nil {
-		return nil, fmt.Errorf("error loading .env file: %v", err)
-	}
+	Env   string `config:"env" env:"EYYGO_ENV"`
+	Debug bool   `config:"debug" env:"DEBUG"`
+
+	Server    ServerConfig    `config:"server"`
+	Database  DatabaseConfig  `config:"database"`
+	Databases []string        `config:"databases" env:"DATABASES"` // extra named databases as name=url pairs
+	JWT       JWTConfig       `config:"jwt"`
+	CORS      CORSConfig      `config:"cors"`
+	RateLimit RateLimitConfig `config:"rate_limit"`
+	Logging   LoggingConfig   `config:"logging"`
+	Templates TemplatesConfig `config:"templates"`
+	Shutdown  ShutdownConfig  `config:"shutdown"`
+	Scheduler SchedulerConfig `config:"scheduler"`
+}
+
+type ServerConfig struct {
+	Host string `config:"host" env:"SERVER_HOST"`
+	Port string `config:"port" env:"SERVER_PORT"`
+}
+
+type DatabaseConfig struct {
+	URL      string `config:"url" env:"DATABASE_URL"` // overrides the individual connection fields when set
+	Driver   string `config:"driver" env:"DB_DRIVER"` // sqlite, mysql, postgresql
+	Host     string `config:"host" env:"DB_HOST"`
+	Port     string `config:"port" env:"DB_PORT"`
+	User     string `config:"user" env:"DB_USER"`
+	Password string `config:"password" env:"DB_PASSWORD"`
+	Name     string `config:"name" env:"DB_NAME"`
+	SSLMode  string `config:"ssl_mode" env:"DB_SSL_MODE"`
+
+	// Read replicas as URLs; reads go to a replica, writes and transactions to the primary
+	Replicas []string `config:"replicas" env:"DB_REPLICAS"`
+
+	// Connection pool
+	MaxOpenConns    int           `config:"max_open_conns" env:"DB_MAX_OPEN_CONNS"` // 0 means unlimited
+	MaxIdleConns    int           `config:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `config:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"` // 0 means forever
+	ConnMaxIdleTime time.Duration `config:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+
+	// Startup retries and timeouts
+	ConnectRetries   int           `config:"connect_retries" env:"DB_CONNECT_RETRIES"`
+	ConnectBackoff   time.Duration `config:"connect_backoff" env:"DB_CONNECT_BACKOFF"`     // doubled after every failed attempt
+	StatementTimeout time.Duration `config:"statement_timeout" env:"DB_STATEMENT_TIMEOUT"` // 0 means no limit
+
+	// SQLite pragmas
+	SQLiteJournalMode string        `config:"sqlite_journal_mode" env:"DB_SQLITE_JOURNAL_MODE"`
+	SQLiteBusyTimeout time.Duration `config:"sqlite_busy_timeout" env:"DB_SQLITE_BUSY_TIMEOUT"`
+	SQLiteForeignKeys bool          `config:"sqlite_foreign_keys" env:"DB_SQLITE_FOREIGN_KEYS"`
+}
+
+type JWTConfig struct {
+	Secret string        `config:"secret" env:"JWT_SECRET"`
+	Expiry time.Duration `config:"expiry" env:"JWT_EXPIRY"`
+}
+
+type CORSConfig struct {
+	AllowOrigins     []string      `config:"allow_origins" env:"CORS_ALLOW_ORIGINS"` // empty means the server's own origin
+	AllowMethods     []string      `config:"allow_methods" env:"CORS_ALLOW_METHODS"`
+	AllowHeaders     []string      `config:"allow_headers" env:"CORS_ALLOW_HEADERS"`
+	AllowCredentials bool          `config:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           time.Duration `config:"max_age" env:"CORS_MAX_AGE"`
+}
+
+type RateLimitConfig struct {
+	Max        int           `config:"max" env:"RATE_LIMIT_MAX"`
+	Expiration time.Duration `config:"expiration" env:"RATE_LIMIT_EXPIRATION"`
+}
+
+type LoggingConfig struct {
+	Level string `config:"level" env:"LOG_LEVEL"` // debug, info, warning, error
+	Color bool   `config:"color" env:"LOG_COLOR"`
+
+	// SQL logging: every query is logged at debug level
+	SlowQueryThreshold time.Duration `config:"slow_query_threshold" env:"LOG_SLOW_QUERY_THRESHOLD"` // 0 disables slow query warnings
+	RedactFields       []string      `config:"redact_fields" env:"LOG_REDACT_FIELDS"`               // columns whose values are hidden in logs
+}
+
+type TemplatesConfig struct {
+	Dir    string `config:"dir" env:"TEMPLATES_DIR"`
+	Ext    string `config:"ext" env:"TEMPLATES_EXT"`
+	Reload bool   `config:"reload" env:"TEMPLATES_RELOAD"`
+}
 
-	config := &Config{
-		// Server settings
-		ServerPort: getEnv("SERVER_PORT", "3000"),
-		ServerHost: getEnv("SERVER_HOST", "localhost"),
-
-		// Database settings
-		DBDriver:   getEnv("DB_DRIVER", "sqlite"), // sqlite, mysql, postgresql
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnv("DB_PORT", "3306"), // default MySQL port
-		DBUser:     getEnv("DB_USER", "root"),
-		DBPassword: getEnv("DB_PASSWORD", ""),
-		DBName:     getEnv("DB_NAME", "test_db"),
-		DBSSLMode:  getEnv("DB_SSL_MODE", "disable"),
+type ShutdownConfig struct {
+	Timeout time.Duration `config:"timeout" env:"SHUTDOWN_TIMEOUT"` // how long in-flight requests may take to drain
+	Delay   time.Duration `config:"delay" env:"SHUTDOWN_DELAY"`     // how long readiness fails before the listener closes
+}
+
+type SchedulerConfig struct {
+	Interval time.Duration `config:"interval" env:"SCHEDULER_INTERVAL"` // how often background jobs run; 0 disables them
+}
+
+// NamedDatabases parses Databases into a name to URL map
+func (c *Config) NamedDatabases() (map[string]string, error) {
+	named := make(map[string]string, len(c.Databases))
+	for _, entry := range c.Databases {
+		name, dsn, ok := strings.Cut(entry, "=")
+		name, dsn = strings.TrimSpace(name), strings.TrimSpace(dsn)
+		if !ok || name == "" || dsn == "" {
+			return nil, fmt.Errorf("%q must be in the form name=url", entry)
+		}
+		if name == DefaultDatabase {
+			return nil, fmt.Errorf("%q is reserved for the primary database settings", DefaultDatabase)
+		}
+		if _, exists := named[name]; exists {
+			return nil, fmt.Errorf("database %q is defined more than once", name)
+		}
+		named[name] = dsn
 	}
+	return named, nil
+}
+
+// Address returns the host:port the server listens on
+func (s ServerConfig) Address() string {
+	return s.Host + ":" + s.Port
+}
+
+// IsProduction reports whether the production profile is active
+func (c *Config) IsProduction() bool {
+	return c.Env == EnvProduction
+}
+
+// defaultConfig returns the lowest configuration layer
+func defaultConfig() *Config {
+	return &Config{
+		Env:   EnvDevelopment,
+		Debug: false,
+
+		Server: ServerConfig{
+			Host: "localhost",
+			Port: "3000",
+		},
+
+		Database: DatabaseConfig{
+			Driver:  "sqlite",
+			Host:    "localhost",
+			Port:    "3306", // default MySQL port
+			User:    "root",
+			Name:    "test_db",
+			SSLMode: "disable",
 
-	return config, nil
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+
+			ConnectRetries: 5,
+			ConnectBackoff: 500 * time.Millisecond,
+
+			SQLiteJournalMode: "WAL",
+			SQLiteBusyTimeout: 5 * time.Second,
+			SQLiteForeignKeys: true,
+		},
+
+		JWT: JWTConfig{
+			Secret: "your-secret-key", // Default secret - change in production
+			Expiry: 24 * time.Hour,
+		},
+
+		CORS: CORSConfig{
+			AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
+			AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+			AllowCredentials: true,
+			MaxAge:           24 * time.Hour,
+		},
+
+		RateLimit: RateLimitConfig{
+			Max:        100,
+			Expiration: time.Minute,
+		},
+
+		Logging: LoggingConfig{
+			Level: "info",
+			Color: true,
+
+			SlowQueryThreshold: 200 * time.Millisecond,
+			RedactFields:       []string{"password", "secret", "token"},
+		},
+
+		Templates: TemplatesConfig{
+			Dir: "./templates",
+			Ext: ".html",
+		},
+
+		Shutdown: ShutdownConfig{
+			Timeout: 10 * time.Second,
+		},
+
+		Scheduler: SchedulerConfig{
+			Interval: 30 * time.Second,
+		},
+	}
 }
 
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// applyProfile adjusts the defaults for the selected profile before any
+// file, environment variable or flag is applied. Debug is never implied; debug
+// logging, which traces every SQL query, only comes with a development profile
+// that was chosen, not with the one used when no profile is set.
+func applyProfile(cfg *Config, chosen bool) {
+	switch cfg.Env {
+	case EnvDevelopment:
+		if chosen {
+			cfg.Logging.Level = "debug"
+		}
+		cfg.Templates.Reload = true
+	case EnvTest:
+		cfg.Database.ConnectRetries = 0
+		cfg.Logging.Level = "warning"
+		cfg.Logging.Color = false
+	case EnvProduction:
+		cfg.Logging.Color = false
+		cfg.Shutdown.Delay = 5 * time.Second
 	}
-	return value
 }
@@ -0,0 +1,380 @@
+// config/loader.go
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileExts lists the supported config file formats in lookup order
+var configFileExts = []string{".yaml", ".yml", ".toml", ".json"}
+
+// setting is one leaf field of Config together with its lookup keys
+type setting struct {
+	key   string // dotted file key, e.g. "server.port"
+	env   string // environment variable, e.g. "SERVER_PORT"
+	value reflect.Value
+}
+
+// flagName returns the command line flag for the setting, e.g. "server-port"
+func (s setting) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+// LoadConfig loads the configuration without command line flags
+func LoadConfig() (*Config, error) {
+	return Load(nil)
+}
+
+// Load builds the configuration from the following layers, each overriding the previous:
+//
+//	defaults < profile defaults < config file < config.<env> file < .env < .env.<env> < environment < flags
+//
+// The profile comes from --env, EYYGO_ENV or the .env files and defaults to development,
+// without the debug logging of a chosen development profile.
+// The config file is found through --config, EYYGO_CONFIG or by looking for
+// config.{yaml,yml,toml,json} in the working directory.
+func Load(args []string) (*Config, error) {
+	cfg := defaultConfig()
+	settings := collectSettings(cfg)
+
+	flagValues, configPath, err := parseFlags(args, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	dotenv, err := readDotenv(".env")
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := func(key string) (string, bool) {
+		if value, ok := os.LookupEnv(key); ok {
+			return value, true
+		}
+		value, ok := dotenv[key]
+		return value, ok
+	}
+
+	// Select the profile before anything else so profile files can be found
+	chosen := true
+	if env, ok := flagValues["env"]; ok {
+		cfg.Env = env
+	} else if env, ok := lookup("EYYGO_ENV"); ok && env != "" {
+		cfg.Env = env
+	} else {
+		chosen = false
+	}
+	applyProfile(cfg, chosen)
+
+	profileDotenv, err := readDotenv(".env." + cfg.Env)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range profileDotenv {
+		dotenv[key] = value
+	}
+
+	var problems []string
+
+	// Config files
+	if configPath == "" {
+		configPath, _ = lookup("EYYGO_CONFIG")
+	}
+	files, err := configFiles(configPath, cfg.Env)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		values, err := readConfigFile(file)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, applyFileValues(settings, file, values)...)
+	}
+
+	// Environment (real variables win over .env files) and flags
+	for _, s := range settings {
+		if value, ok := lookup(s.env); ok {
+			if err := setValue(s.value, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", s.env, err))
+			}
+		}
+		if value, ok := flagValues[s.flagName()]; ok {
+			if err := setValue(s.value, value); err != nil {
+				problems = append(problems, fmt.Sprintf("--%s: %v", s.flagName(), err))
+			}
+		}
+	}
+
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+
+	return cfg, nil
+}
+
+// collectSettings walks Config and returns every tagged leaf field
+func collectSettings(cfg *Config) []setting {
+	var settings []setting
+
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			key := field.Tag.Get("config")
+			if key == "" {
+				continue
+			}
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Duration(0)) {
+				walk(v.Field(i), key)
+				continue
+			}
+			settings = append(settings, setting{
+				key:   key,
+				env:   field.Tag.Get("env"),
+				value: v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+
+	return settings
+}
+
+// flagValue records a command line value as a string so it can be applied
+// through the same parser as environment variables
+type flagValue struct {
+	name   string
+	isBool bool
+	values map[string]string
+}
+
+func (f *flagValue) String() string { return "" }
+
+func (f *flagValue) Set(value string) error {
+	f.values[f.name] = value
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool { return f.isBool }
+
+// parseFlags registers one flag per setting plus --config and returns the flags that were set
+func parseFlags(args []string, settings []setting) (map[string]string, string, error) {
+	values := make(map[string]string)
+	fs := flag.NewFlagSet("eyygo", flag.ContinueOnError)
+
+	configPath := fs.String("config", "", "path to a YAML, TOML or JSON config file (EYYGO_CONFIG)")
+	for _, s := range settings {
+		fs.Var(&flagValue{
+			name:   s.flagName(),
+			isBool: s.value.Kind() == reflect.Bool,
+			values: values,
+		}, s.flagName(), "overrides "+s.env)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, "", err
+	}
+	return values, *configPath, nil
+}
+
+// readDotenv reads a dotenv file without exporting it to the process environment.
+// A missing file is not an error so containers can rely on real variables.
+func readDotenv(path string) (map[string]string, error) {
+	values, err := godotenv.Read(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]string), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading %s file: %v", path, err)
+	}
+	return values, nil
+}
+
+// configFiles returns the base config file and its profile override, if they exist.
+// An explicit path must exist; discovered files are optional.
+func configFiles(explicit, env string) ([]string, error) {
+	var files []string
+
+	base := explicit
+	if base != "" {
+		if _, err := os.Stat(base); err != nil {
+			return nil, fmt.Errorf("error loading config file: %v", err)
+		}
+	} else {
+		for _, ext := range configFileExts {
+			if fileExists("config" + ext) {
+				base = "config" + ext
+				break
+			}
+		}
+	}
+	if base == "" {
+		return nil, nil
+	}
+	files = append(files, base)
+
+	ext := filepath.Ext(base)
+	profile := strings.TrimSuffix(base, ext) + "." + env + ext
+	if fileExists(profile) {
+		files = append(files, profile)
+	}
+
+	return files, nil
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// readConfigFile decodes a config file into a generic nested map
+func readConfigFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading config file: %v", err)
+	}
+
+	values := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("unsupported config file format: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+
+	return values, nil
+}
+
+// applyFileValues flattens the nested file values into dotted keys and sets the matching settings
+func applyFileValues(settings []setting, file string, values map[string]interface{}) []string {
+	flat := make(map[string]interface{})
+	flatten("", values, flat)
+
+	byKey := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var problems []string
+	for _, key := range keys {
+		if key == "env" {
+			problems = append(problems, fmt.Sprintf("%s: the profile can only be selected with EYYGO_ENV or --env", file))
+			continue
+		}
+		s, ok := byKey[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: unknown setting %q", file, key))
+			continue
+		}
+		if err := setValue(s.value, fileValueString(flat[key])); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s: %v", file, key, err))
+		}
+	}
+	return problems
+}
+
+func flatten(prefix string, values map[string]interface{}, out map[string]interface{}) {
+	for key, value := range values {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flatten(key, nested, out)
+			continue
+		}
+		out[key] = value
+	}
+}
+
+// fileValueString renders a decoded file value in the same format as an environment variable
+func fileValueString(value interface{}) string {
+	if list, ok := value.([]interface{}); ok {
+		parts := make([]string, len(list))
+		for i, item := range list {
+			parts[i] = scalarString(item)
+		}
+		return strings.Join(parts, ",")
+	}
+	return scalarString(value)
+}
+
+// scalarString formats a decoded scalar; JSON numbers are float64 and must
+// not turn into exponents, e.g. 10485760 rather than 1.048576e+07
+func scalarString(value interface{}) string {
+	if f, ok := value.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// setValue parses raw into the field according to its type
+func setValue(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		i, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(int64(i))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// config/security.go
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// minJWTSecretLength is the shortest JWT secret accepted for HS256 (256 bits)
+const minJWTSecretLength = 32
+
+// insecureJWTSecrets are placeholder secrets shipped in defaults and samples
+var insecureJWTSecrets = []string{
+	"your-secret-key",
+	"your-super-secret-key-change-this-in-production",
+	"secret",
+	"changeme",
+}
+
+// SecurityIssue is one problem found by CheckSecurity, similar to Django's deploy checks
+type SecurityIssue struct {
+	ID      string
+	Message string
+	Hint    string
+}
+
+func (i SecurityIssue) String() string {
+	return fmt.Sprintf("(%s) %s HINT: %s", i.ID, i.Message, i.Hint)
+}
+
+// CheckSecurity reports settings that are unsafe to deploy with. Callers decide
+// whether issues are fatal (production) or warnings (development and test).
+func (c *Config) CheckSecurity() []SecurityIssue {
+	var issues []SecurityIssue
+
+	for _, secret := range insecureJWTSecrets {
+		if c.JWT.Secret == secret {
+			issues = append(issues, SecurityIssue{
+				ID:      "security.E001",
+				Message: "JWT_SECRET is a well-known default value.",
+				Hint:    "Generate a random secret, e.g. `openssl rand -base64 48`.",
+			})
+			break
+		}
+	}
+	if len(c.JWT.Secret) < minJWTSecretLength {
+		issues = append(issues, SecurityIssue{
+			ID:      "security.E002",
+			Message: fmt.Sprintf("JWT_SECRET is %d characters long.", len(c.JWT.Secret)),
+			Hint:    fmt.Sprintf("Use a secret of at least %d characters.", minJWTSecretLength),
+		})
+	}
+
+	driver, password := c.Database.Driver, c.Database.Password
+	if c.Database.URL != "" {
+		if u, err := url.Parse(c.Database.URL); err == nil {
+			driver = u.Scheme
+			password, _ = u.User.Password()
+		}
+	}
+	if !strings.HasPrefix(driver, "sqlite") && password == "" {
+		issues = append(issues, SecurityIssue{
+			ID:      "security.E003",
+			Message: fmt.Sprintf("The database password is empty for the %s database.", driver),
+			Hint:    "Set a password for the database user.",
+		})
+	}
+
+	if c.CORS.AllowCredentials {
+		for _, origin := range c.CORS.AllowOrigins {
+			if strings.TrimSpace(origin) == "*" {
+				issues = append(issues, SecurityIssue{
+					ID:      "security.E004",
+					Message: "CORS_ALLOW_ORIGINS contains '*' while CORS_ALLOW_CREDENTIALS is true.",
+					Hint:    "List the trusted origins explicitly or disable credentials.",
+				})
+				break
+			}
+		}
+	}
+
+	if c.Debug {
+		issues = append(issues, SecurityIssue{
+			ID:      "security.E005",
+			Message: "DEBUG is enabled.",
+			Hint:    "Set DEBUG=false in deployment.",
+		})
+	}
+
+	return issues
+}
@@ -0,0 +1,265 @@
+// diff/diff.go
+
+// Package diff compares texts line by line, for unified diffs, or word by
+// word, using the Myers algorithm so the edits found are the fewest possible.
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Kinds of Op
+const (
+	Equal  = "equal"
+	Insert = "insert"
+	Delete = "delete"
+)
+
+// DefaultContext is the number of unchanged lines shown around the changes
+// of a unified diff
+const DefaultContext = 3
+
+// Op is a run of text kept, inserted or deleted going from one text to the other
+type Op struct {
+	Kind string `json:"op"`
+	Text string `json:"text"`
+}
+
+var wordPattern = regexp.MustCompile(`\s+|[^\s]+`)
+
+// Lines compares two texts line by line, one Op per line without its newline
+func Lines(from, to string) []Op {
+	return compare(splitLines(from), splitLines(to))
+}
+
+// Words compares two texts word by word; consecutive words of the same kind
+// are joined into one Op, whitespace included, so that joining the Text of
+// the Equal and Delete ops gives back from and of Equal and Insert gives to
+func Words(from, to string) []Op {
+	ops := compare(wordPattern.FindAllString(from, -1), wordPattern.FindAllString(to, -1))
+
+	var merged []Op
+	for _, op := range ops {
+		if n := len(merged); n > 0 && merged[n-1].Kind == op.Kind {
+			merged[n-1].Text += op.Text
+			continue
+		}
+		merged = append(merged, op)
+	}
+	return merged
+}
+
+// Unified formats the line differences of two texts as a unified diff with
+// the given lines of context, labelling them fromName and toName. It is
+// empty when the texts have the same lines.
+func Unified(from, to, fromName, toName string, context int) string {
+	ops := Lines(from, to)
+	if !changed(ops) {
+		return ""
+	}
+	if context < 0 {
+		context = 0
+	}
+
+	// Line numbers in from and to where each op starts
+	fromLine := make([]int, len(ops)+1)
+	toLine := make([]int, len(ops)+1)
+	for i, op := range ops {
+		fromLine[i+1], toLine[i+1] = fromLine[i], toLine[i]
+		if op.Kind != Insert {
+			fromLine[i+1]++
+		}
+		if op.Kind != Delete {
+			toLine[i+1]++
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+	for i := 0; i < len(ops); {
+		for i < len(ops) && ops[i].Kind == Equal {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+
+		// A hunk runs from context lines before its first change to context
+		// lines after its last, and absorbs changes closer than twice that
+		start, end := max(i-context, 0), i
+		for {
+			for end < len(ops) && ops[end].Kind != Equal {
+				end++
+			}
+			next := end
+			for next < len(ops) && ops[next].Kind == Equal {
+				next++
+			}
+			if next < len(ops) && next-end <= 2*context {
+				end = next
+				continue
+			}
+			end = min(end+context, len(ops))
+			break
+		}
+
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n",
+			hunkRange(fromLine[start], fromLine[end]-fromLine[start]),
+			hunkRange(toLine[start], toLine[end]-toLine[start]))
+		for _, op := range ops[start:end] {
+			switch op.Kind {
+			case Insert:
+				b.WriteByte('+')
+			case Delete:
+				b.WriteByte('-')
+			default:
+				b.WriteByte(' ')
+			}
+			b.WriteString(op.Text)
+			b.WriteByte('\n')
+		}
+		i = end
+	}
+	return b.String()
+}
+
+// hunkRange formats the start and length of a hunk side; lines count from
+// one, and an empty side is placed at the line before it
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func changed(ops []Op) bool {
+	for _, op := range ops {
+		if op.Kind != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// splitLines splits text into lines, ignoring the newline ending the last
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// maxEdits bounds the work of compare; texts further apart than that are
+// shown as entirely replaced, past their common start and end
+const maxEdits = 2000
+
+// compare finds the shortest edit script from a to b with the Myers
+// algorithm, one Op per element
+func compare(a, b []string) []Op {
+	var head, tail []Op
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		head = append(head, Op{Kind: Equal, Text: a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		tail = append(tail, Op{Kind: Equal, Text: a[len(a)-1]})
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	middle, ok := myers(a, b)
+	if !ok {
+		middle = middle[:0]
+		for _, text := range a {
+			middle = append(middle, Op{Kind: Delete, Text: text})
+		}
+		for _, text := range b {
+			middle = append(middle, Op{Kind: Insert, Text: text})
+		}
+	}
+
+	ops := append(head, middle...)
+	for i := len(tail) - 1; i >= 0; i-- {
+		ops = append(ops, tail[i])
+	}
+	return ops
+}
+
+// myers returns the edit script from a to b, or false when it needs more
+// than maxEdits edits
+func myers(a, b []string) ([]Op, bool) {
+	n, m := len(a), len(b)
+	offset := n + m
+	// v[offset+k] is the furthest x reached on diagonal k = x - y; trace[d]
+	// keeps diagonals -d to d of it as they were before round d
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= n+m && d <= maxEdits && !found; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	// Walk back through the furthest points of each round, collecting the
+	// ops in reverse
+	var ops []Op
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		row := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && row[k-1+d] < row[k+1+d]) {
+			prevK = k + 1
+		}
+		prevX := row[prevK+d]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, Op{Kind: Equal, Text: a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, Op{Kind: Insert, Text: b[y-1]})
+			y--
+		} else {
+			ops = append(ops, Op{Kind: Delete, Text: a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, Op{Kind: Equal, Text: a[x-1]})
+		x--
+		y--
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops, true
+}
@@ -2,34 +2,118 @@
 package handlers
 
 import (
-	"reflect"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/mviner000/eyygo/admin"
+	"github.com/mviner000/eyygo/logger"
+	"github.com/mviner000/eyygo/middleware"
+	"github.com/mviner000/eyygo/settings"
 	"gorm.io/gorm"
 )
 
 type AdminHandler struct {
 	db *gorm.DB
+
+	// Databases gives access to named databases; set ModelAdmin.DB to one of them to route a model
+	Databases *settings.Databases
 }
 
 func NewAdminHandler(db *gorm.DB) *AdminHandler {
 	return &AdminHandler{db: db}
 }
 
-// ListModels returns all registered models in the admin interface
+// ListModels describes all registered models in the admin interface, sorted by name
 func (h *AdminHandler) ListModels(c *fiber.Ctx) error {
-	models := admin.Site.GetRegisteredModels()
+	models := make([]admin.ModelSchema, 0, len(admin.Site.GetModelNames()))
+	for _, name := range admin.Site.GetModelNames() {
+		modelAdmin, _ := admin.Site.GetModelAdmin(name)
+		described, err := modelAdmin.Describe()
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to describe model " + name,
+			})
+		}
+		models = append(models, described)
+	}
 	return c.JSON(fiber.Map{
 		"models": models,
 	})
 }
 
-// ListModelEntries returns paginated entries for a specific model
+// ModelSchema describes the fields, relations and actions of a model
+func (h *AdminHandler) ModelSchema(c *fiber.Ctx) error {
+	modelAdmin, exists := admin.Site.GetModelAdmin(c.Params("model"))
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Model not found",
+		})
+	}
+
+	described, err := modelAdmin.Describe()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to describe model",
+		})
+	}
+	return c.JSON(described)
+}
+
+// databaseStatus describes a configured database and its connection pool
+type databaseStatus struct {
+	Name            string `json:"name"`
+	Driver          string `json:"driver"`
+	Healthy         bool   `json:"healthy"`
+	Error           string `json:"error,omitempty"`
+	OpenConnections int    `json:"open_connections"`
+	InUse           int    `json:"in_use"`
+	Idle            int    `json:"idle"`
+}
+
+// ListDatabases reports every configured database, the primary and those
+// named in DATABASES, with whether it answers and its pool usage
+func (h *AdminHandler) ListDatabases(c *fiber.Ctx) error {
+	if h.Databases == nil {
+		return c.JSON(fiber.Map{
+			"data": []databaseStatus{},
+		})
+	}
+
+	names := h.Databases.Names()
+	data := make([]databaseStatus, 0, len(names))
+	for _, name := range names {
+		db, _ := h.Databases.Using(name)
+		status := databaseStatus{Name: name, Driver: db.Dialector.Name()}
+		sqlDB, err := db.DB()
+		if err == nil {
+			err = sqlDB.PingContext(c.UserContext())
+			stats := sqlDB.Stats()
+			status.OpenConnections, status.InUse, status.Idle = stats.OpenConnections, stats.InUse, stats.Idle
+		}
+		if err != nil {
+			status.Error = err.Error()
+		}
+		status.Healthy = err == nil
+		data = append(data, status)
+	}
+
+	return c.JSON(fiber.Map{
+		"data": data,
+	})
+}
+
+// ListModelEntries returns paginated entries for a specific model, by page or
+// by cursor (see paginate)
 func (h *AdminHandler) ListModelEntries(c *fiber.Ctx) error {
 	modelName := c.Params("model")
-	page := c.QueryInt("page", 1)
-	perPage := c.QueryInt("per_page", 10)
 
 	modelAdmin, exists := admin.Site.GetModelAdmin(modelName)
 	if !exists {
@@ -38,28 +122,134 @@ func (h *AdminHandler) ListModelEntries(c *fiber.Ctx) error {
 		})
 	}
 
-	var results []interface{}
-	var count int64
+	params := modelAdmin.ParseListParams(c.Queries())
+	query, err := modelAdmin.Filter(modelAdmin.DB.WithContext(c.UserContext()).Model(modelAdmin.Model), params)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 
-	query := modelAdmin.DB.Model(modelAdmin.Model)
-	query.Count(&count)
+	return paginate(c, modelAdmin, query, params)
+}
 
-	offset := (page - 1) * perPage
-	err := query.Offset(offset).Limit(perPage).Find(&results).Error
+// AutocompleteModelEntries returns entries matching ?q= as id/label choices,
+// for picking related objects
+func (h *AdminHandler) AutocompleteModelEntries(c *fiber.Ctx) error {
+	modelAdmin, exists := admin.Site.GetModelAdmin(c.Params("model"))
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Model not found",
+		})
+	}
+
+	choices, err := modelAdmin.Autocomplete(modelAdmin.DB.WithContext(c.UserContext()), c.Query("q"), c.QueryInt("limit"))
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to fetch entries",
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
 		})
 	}
 
 	return c.JSON(fiber.Map{
-		"data":        results,
-		"total":       count,
-		"page":        page,
-		"total_pages": (count + int64(perPage) - 1) / int64(perPage),
+		"data": choices,
 	})
 }
 
+// ExportModelEntries streams every entry matching the list parameters as CSV, JSON or XLSX
+func (h *AdminHandler) ExportModelEntries(c *fiber.Ctx) error {
+	modelAdmin, exists := admin.Site.GetModelAdmin(c.Params("model"))
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Model not found",
+		})
+	}
+
+	format := c.Query("format", admin.ExportCSV)
+	contentType, ok := admin.ExportContentTypes[format]
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Unsupported format, use csv, json or xlsx",
+		})
+	}
+
+	params := modelAdmin.ParseListParams(c.Queries())
+	query, err := modelAdmin.ApplyFilters(modelAdmin.DB.WithContext(c.UserContext()).Model(modelAdmin.Model), params)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", modelAdmin.Name, time.Now().Format("20060102-150405"), format)
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+
+	// The body is written after the handler returns; errors past this point
+	// can only be logged and end the download early
+	requestID := logger.RequestIDFromContext(c.UserContext())
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := modelAdmin.Export(w, query, format); err != nil {
+			log.Printf("[ERROR] request_id=%s export of %s failed: %v", requestID, modelAdmin.Name, err)
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// ImportModelEntries creates or updates entries from an uploaded CSV or JSON
+// file. With ?dry_run=true nothing is saved and the report shows what would
+// happen; otherwise the import is committed only if every row is valid.
+func (h *AdminHandler) ImportModelEntries(c *fiber.Ctx) error {
+	modelAdmin, exists := admin.Site.GetModelAdmin(c.Params("model"))
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Model not found",
+		})
+	}
+
+	// The file is either a multipart "file" field or the raw request body
+	var body io.Reader = bytes.NewReader(c.Body())
+	format := c.Query("format")
+	if file, err := c.FormFile("file"); err == nil {
+		opened, err := file.Open()
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Could not read uploaded file",
+			})
+		}
+		defer opened.Close()
+		body = opened
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(file.Filename)), ".")
+		}
+	}
+	if format == "" {
+		format = admin.ExportCSV
+		if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+			format = admin.ExportJSON
+		}
+	}
+
+	rows, err := admin.ReadImport(body, format)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	result, err := modelAdmin.Import(middleware.AuditContext(c), rows, c.Query("key"), c.QueryBool("dry_run"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if result.Failed > 0 && !result.DryRun {
+		return c.Status(422).JSON(result)
+	}
+	return c.JSON(result)
+}
+
 // GetModelEntry returns a specific model entry
 func (h *AdminHandler) GetModelEntry(c *fiber.Ctx) error {
 	modelName := c.Params("model")
@@ -72,15 +262,18 @@ func (h *AdminHandler) GetModelEntry(c *fiber.Ctx) error {
 		})
 	}
 
-	var result interface{}
-	err := modelAdmin.DB.First(&result, id).Error
+	result, err := modelAdmin.FindEntry(modelAdmin.Preloaded(modelAdmin.DB.WithContext(c.UserContext())), id)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"error": "Entry not found",
 		})
 	}
 
-	return c.JSON(result)
+	c.Set(fiber.HeaderETag, modelAdmin.ETag(result))
+	if ifNoneMatch := c.Get(fiber.HeaderIfNoneMatch); ifNoneMatch != "" && modelAdmin.MatchesETag(result, ifNoneMatch) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	return c.JSON(admin.Redact(result))
 }
 
 // CreateModelEntry creates a new model entry
@@ -94,23 +287,24 @@ func (h *AdminHandler) CreateModelEntry(c *fiber.Ctx) error {
 		})
 	}
 
-	entry := reflect.New(reflect.TypeOf(modelAdmin.Model).Elem()).Interface()
-	if err := c.BodyParser(entry); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	entry := modelAdmin.NewEntry()
+	if err := modelAdmin.SetJSON(c.UserContext(), entry, c.Body(), true); err != nil {
+		return fieldsError(c, err)
 	}
 
-	if err := modelAdmin.DB.Create(entry).Error; err != nil {
+	if err := modelAdmin.CreateEntry(middleware.AuditContext(c), entry); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to create entry",
 		})
 	}
 
-	return c.JSON(entry)
+	return c.JSON(admin.Redact(entry))
 }
 
-// UpdateModelEntry updates a specific model entry
+// UpdateModelEntry updates the FormFields of a specific model entry from a
+// JSON object; other members are ignored. With an If-Match header
+// (the ETag of a GET) the update only succeeds if nobody changed the entry
+// since; otherwise it responds 409 with the current entry.
 func (h *AdminHandler) UpdateModelEntry(c *fiber.Ctx) error {
 	modelName := c.Params("model")
 	id := c.Params("id")
@@ -122,26 +316,140 @@ func (h *AdminHandler) UpdateModelEntry(c *fiber.Ctx) error {
 		})
 	}
 
-	entry := reflect.New(reflect.TypeOf(modelAdmin.Model).Elem()).Interface()
-	if err := modelAdmin.DB.First(entry, id).Error; err != nil {
+	entry, err := modelAdmin.FindEntry(modelAdmin.DB.WithContext(c.UserContext()), id)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Entry not found",
+		})
+	}
+
+	ifMatch := c.Get(fiber.HeaderIfMatch)
+	if ifMatch != "" && !modelAdmin.MatchesETag(entry, ifMatch) {
+		return h.conflict(c, modelAdmin, entry)
+	}
+
+	// Only FormFields are taken from the body; the primary key stays :id, so
+	// the entry checked against If-Match is the one saved
+	before := modelAdmin.Snapshot(entry)
+	if err := modelAdmin.SetJSON(c.UserContext(), entry, c.Body(), false); err != nil {
+		return fieldsError(c, err)
+	}
+
+	var conflict *admin.ConflictError
+	err = modelAdmin.UpdateEntryIfMatch(middleware.AuditContext(c), ifMatch, before, entry)
+	if errors.As(err, &conflict) {
+		return h.conflict(c, modelAdmin, conflict.Current)
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update entry",
+		})
+	}
+
+	// Reload, so the ETag reflects the stored precision of the timestamps
+	if saved, err := modelAdmin.FindEntry(modelAdmin.Preloaded(modelAdmin.DB.WithContext(c.UserContext())), id); err == nil {
+		entry = saved
+	}
+	c.Set(fiber.HeaderETag, modelAdmin.ETag(entry))
+	return c.JSON(admin.Redact(entry))
+}
+
+// PatchModelEntry updates only the fields touched by a JSON Merge Patch
+// (application/merge-patch+json) or JSON Patch (application/json-patch+json).
+// Like UpdateModelEntry it honours If-Match.
+func (h *AdminHandler) PatchModelEntry(c *fiber.Ctx) error {
+	modelAdmin, exists := admin.Site.GetModelAdmin(c.Params("model"))
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Model not found",
+		})
+	}
+
+	format, _, _ := strings.Cut(c.Get(fiber.HeaderContentType), ";")
+	format = strings.TrimSpace(format)
+	if format != admin.MergePatch && format != admin.JSONPatch {
+		return c.Status(415).JSON(fiber.Map{
+			"error": "Use " + admin.MergePatch + " or " + admin.JSONPatch,
+		})
+	}
+
+	id := c.Params("id")
+	entry, err := modelAdmin.FindEntry(modelAdmin.DB.WithContext(c.UserContext()), id)
+	if err != nil {
 		return c.Status(404).JSON(fiber.Map{
 			"error": "Entry not found",
 		})
 	}
 
-	if err := c.BodyParser(entry); err != nil {
+	ifMatch := c.Get(fiber.HeaderIfMatch)
+	if ifMatch != "" && !modelAdmin.MatchesETag(entry, ifMatch) {
+		return h.conflict(c, modelAdmin, entry)
+	}
+
+	before := modelAdmin.Snapshot(entry)
+	columns, err := modelAdmin.ApplyPatch(c.UserContext(), entry, format, c.Body())
+	var (
+		patchErr    *admin.PatchError
+		fieldErrors admin.FieldErrors
+	)
+	switch {
+	case errors.As(err, &patchErr):
 		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
+			"error": err.Error(),
+		})
+	case errors.Is(err, admin.ErrPatchTestFailed):
+		return h.conflict(c, modelAdmin, entry)
+	case errors.As(err, &fieldErrors):
+		return c.Status(422).JSON(fiber.Map{
+			"error":  "Invalid values",
+			"fields": fieldErrors,
+		})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to apply patch",
 		})
 	}
 
-	if err := modelAdmin.DB.Save(entry).Error; err != nil {
+	var conflict *admin.ConflictError
+	err = modelAdmin.PatchEntryIfMatch(middleware.AuditContext(c), ifMatch, before, entry, columns)
+	if errors.As(err, &conflict) {
+		return h.conflict(c, modelAdmin, conflict.Current)
+	}
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to update entry",
 		})
 	}
 
-	return c.JSON(entry)
+	if saved, err := modelAdmin.FindEntry(modelAdmin.Preloaded(modelAdmin.DB.WithContext(c.UserContext())), id); err == nil {
+		entry = saved
+	}
+	c.Set(fiber.HeaderETag, modelAdmin.ETag(entry))
+	return c.JSON(admin.Redact(entry))
+}
+
+// fieldsError responds to an error of SetJSON: 400 for a body that is not a
+// JSON object, 422 with the invalid fields otherwise
+func fieldsError(c *fiber.Ctx, err error) error {
+	var fieldErrors admin.FieldErrors
+	if errors.As(err, &fieldErrors) {
+		return c.Status(422).JSON(fiber.Map{
+			"error":  "Invalid values",
+			"fields": fieldErrors,
+		})
+	}
+	return c.Status(400).JSON(fiber.Map{
+		"error": "Invalid request body",
+	})
+}
+
+// conflict responds 409 with the entry as currently stored and its ETag
+func (h *AdminHandler) conflict(c *fiber.Ctx, modelAdmin *admin.ModelAdmin, current interface{}) error {
+	c.Set(fiber.HeaderETag, modelAdmin.ETag(current))
+	return c.Status(409).JSON(fiber.Map{
+		"error":   "The entry was changed by someone else",
+		"current": admin.Redact(current),
+	})
 }
 
 // DeleteModelEntry deletes a specific model entry
@@ -156,8 +464,14 @@ func (h *AdminHandler) DeleteModelEntry(c *fiber.Ctx) error {
 		})
 	}
 
-	entry := reflect.New(reflect.TypeOf(modelAdmin.Model).Elem()).Interface()
-	if err := modelAdmin.DB.Delete(entry, id).Error; err != nil {
+	entry, err := modelAdmin.FindEntry(modelAdmin.DB.WithContext(c.UserContext()), id)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Entry not found",
+		})
+	}
+
+	if err := modelAdmin.DeleteEntry(middleware.AuditContext(c), entry); err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to delete entry",
 		})
@@ -165,3 +479,143 @@ func (h *AdminHandler) DeleteModelEntry(c *fiber.Ctx) error {
 
 	return c.SendStatus(204)
 }
+
+// ActionRequest is the body of a bulk action request
+type ActionRequest struct {
+	IDs []interface{} `json:"ids"`
+}
+
+// ListModelActions returns the bulk actions available for a model
+func (h *AdminHandler) ListModelActions(c *fiber.Ctx) error {
+	modelAdmin, exists := admin.Site.GetModelAdmin(c.Params("model"))
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Model not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"actions": modelAdmin.GetActions(),
+	})
+}
+
+// RunModelAction runs a bulk action on the selected entries in one transaction.
+// Rows that fail are listed in the result; the others are kept.
+func (h *AdminHandler) RunModelAction(c *fiber.Ctx) error {
+	modelAdmin, exists := admin.Site.GetModelAdmin(c.Params("model"))
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Model not found",
+		})
+	}
+
+	var req ActionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	ids := admin.ParseIDs(req.IDs)
+	if len(ids) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "No entries selected",
+		})
+	}
+
+	result, err := modelAdmin.RunAction(middleware.AuditContext(c), c.Params("action"), ids)
+	if errors.Is(err, admin.ErrActionNotFound) {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Action not found",
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Action failed: " + err.Error(),
+		})
+	}
+
+	return c.JSON(result)
+}
+
+// RestoreModelEntry undoes the soft deletion of an entry
+func (h *AdminHandler) RestoreModelEntry(c *fiber.Ctx) error {
+	modelAdmin, exists := admin.Site.GetModelAdmin(c.Params("model"))
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Model not found",
+		})
+	}
+
+	entry, err := modelAdmin.FindEntry(modelAdmin.DB.WithContext(c.UserContext()).Unscoped(), c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Entry not found",
+		})
+	}
+
+	err = modelAdmin.RestoreEntry(middleware.AuditContext(c), entry)
+	if errors.Is(err, admin.ErrNotDeleted) {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Entry is not deleted",
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to restore entry",
+		})
+	}
+
+	return c.JSON(admin.Redact(entry))
+}
+
+// PurgeModelEntry permanently deletes an entry, including soft-deleted ones
+func (h *AdminHandler) PurgeModelEntry(c *fiber.Ctx) error {
+	modelAdmin, exists := admin.Site.GetModelAdmin(c.Params("model"))
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Model not found",
+		})
+	}
+
+	entry, err := modelAdmin.FindEntry(modelAdmin.DB.WithContext(c.UserContext()).Unscoped(), c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Entry not found",
+		})
+	}
+
+	err = modelAdmin.PurgeEntry(middleware.AuditContext(c), entry)
+	if errors.Is(err, admin.ErrInUse) {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "The entry is still referenced by other entries",
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to purge entry",
+		})
+	}
+
+	return c.SendStatus(204)
+}
+
+// ModelEntryHistory returns the audit log of an entry, most recent first
+func (h *AdminHandler) ModelEntryHistory(c *fiber.Ctx) error {
+	modelAdmin, exists := admin.Site.GetModelAdmin(c.Params("model"))
+	if !exists {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Model not found",
+		})
+	}
+
+	entries, err := modelAdmin.History(modelAdmin.DB.WithContext(c.UserContext()), c.Params("id"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch history",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": entries,
+	})
+}
@@ -1,23 +1,28 @@
 package handlers
 
 import (
+	"html"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/mviner000/eyygo/middleware"
 	"github.com/mviner000/eyygo/models"
 	"gorm.io/gorm"
 )
 
 type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" form:"username"`
+	Password string `json:"password" form:"password"`
+	Next     string `json:"next" form:"next"`
 }
 
 type AuthHandler struct {
-	DB          *gorm.DB
-	JWTSecret   []byte
-	TokenExpiry time.Duration
+	DB            *gorm.DB
+	JWTSecret     []byte
+	TokenExpiry   time.Duration
+	SecureCookies bool // only send the auth cookie over HTTPS
 }
 
 func NewAuthHandler(db *gorm.DB, jwtSecret []byte) *AuthHandler {
@@ -37,16 +42,17 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	}
 
 	var user models.User
-	if err := h.DB.Where("username = ?", req.Username).First(&user).Error; err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid credentials",
-		})
+	if err := h.DB.WithContext(c.UserContext()).Where("username = ?", req.Username).First(&user).Error; err != nil {
+		return loginError(c, "Invalid credentials")
 	}
 
 	if !user.CheckPassword(req.Password) {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid credentials",
-		})
+		return loginError(c, "Invalid credentials")
+	}
+
+	// The HTMX form is the admin login, which only staff may use
+	if c.Get("HX-Request") == "true" && !user.IsStaff {
+		return loginError(c, "This account cannot access the admin")
 	}
 
 	// Create token
@@ -71,7 +77,27 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	// Update last login
 	now := time.Now()
 	user.LastLogin = &now
-	h.DB.Save(&user)
+	h.DB.WithContext(c.UserContext()).Save(&user)
+
+	// Browsers (the admin) authenticate with an HttpOnly cookie; API clients use the token
+	c.Cookie(&fiber.Cookie{
+		Name:     middleware.AuthCookie,
+		Value:    t,
+		Path:     "/",
+		Expires:  now.Add(h.TokenExpiry),
+		HTTPOnly: true,
+		Secure:   h.SecureCookies,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+
+	if c.Get("HX-Request") == "true" {
+		next := "/admin/"
+		if strings.HasPrefix(req.Next, "/admin/") {
+			next = req.Next
+		}
+		c.Set("HX-Redirect", next)
+		return c.SendString("")
+	}
 
 	return c.JSON(fiber.Map{
 		"token": t,
@@ -84,6 +110,33 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	})
 }
 
+// Logout clears the auth cookie and returns to the admin login page
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	c.Cookie(&fiber.Cookie{
+		Name:     middleware.AuthCookie,
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		HTTPOnly: true,
+		Secure:   h.SecureCookies,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+	return c.Redirect(middleware.AdminLoginURL)
+}
+
+// loginError answers HTMX forms with an HTML fragment and API clients with JSON
+func loginError(c *fiber.Ctx, message string) error {
+	if c.Get("HX-Request") == "true" {
+		return c.Status(fiber.StatusUnauthorized).SendString(`
+            <div class="text-red-500 text-sm mt-1">
+                ` + html.EscapeString(message) + `
+            </div>
+        `)
+	}
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"error": message,
+	})
+}
+
 func (h *AuthHandler) ValidateToken(c *fiber.Ctx) error {
 	user := c.Locals("user").(*jwt.Token)
 	claims := user.Claims.(jwt.MapClaims)
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"context"
+
 	"gorm.io/gorm"
 )
 
@@ -14,11 +16,11 @@ func NewDBHandler(db *gorm.DB) *DBHandler {
 	}
 }
 
-// Example of a database operation
-func (h *DBHandler) CheckHealth() error {
+// CheckHealth pings the database, giving up when ctx is done
+func (h *DBHandler) CheckHealth(ctx context.Context) error {
 	sqlDB, err := h.DB.DB()
 	if err != nil {
 		return err
 	}
-	return sqlDB.Ping()
+	return sqlDB.PingContext(ctx)
 }
@@ -0,0 +1,34 @@
+// handlers/health.go
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mviner000/eyygo/health"
+)
+
+type HealthHandler struct {
+	registry *health.Registry
+}
+
+func NewHealthHandler(registry *health.Registry) *HealthHandler {
+	return &HealthHandler{registry: registry}
+}
+
+// Live reports whether the process is alive
+func (h *HealthHandler) Live(c *fiber.Ctx) error {
+	return sendReport(c, h.registry.Live(c.UserContext()))
+}
+
+// Ready reports whether the process can serve traffic
+func (h *HealthHandler) Ready(c *fiber.Ctx) error {
+	return sendReport(c, h.registry.Ready(c.UserContext()))
+}
+
+func sendReport(c *fiber.Ctx, report health.Report) error {
+	status := fiber.StatusOK
+	if !report.Healthy() {
+		status = fiber.StatusServiceUnavailable
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(status).JSON(report)
+}
@@ -0,0 +1,249 @@
+// handlers/note_revisions.go
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mviner000/eyygo/diff"
+	"github.com/mviner000/eyygo/models"
+	"gorm.io/gorm"
+)
+
+// Modes of DiffRevisions
+const (
+	diffUnified = "unified"
+	diffWords   = "words"
+)
+
+// revisionSummary is a revision as listed, without its content
+type revisionSummary struct {
+	ID        uint      `json:"id"`
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	Tags      []string  `json:"tags"`
+	EditorID  *uint     `json:"editor_id"`
+	Editor    string    `json:"editor,omitempty"` // username
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// revisionResponse is a revision with its content
+type revisionResponse struct {
+	revisionSummary
+	Content string `json:"content"` // Markdown
+}
+
+// tagChanges are the tags added and removed between two revisions
+type tagChanges struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// revisionDiff compares two revisions: the title word by word and the content
+// as a unified diff or word by word, depending on the mode
+type revisionDiff struct {
+	From    revisionSummary `json:"from"`
+	To      revisionSummary `json:"to"`
+	Mode    string          `json:"mode"`
+	Title   []diff.Op       `json:"title"`
+	Tags    tagChanges      `json:"tags"`
+	Unified *string         `json:"unified,omitempty"`
+	Content []diff.Op       `json:"content,omitempty"`
+}
+
+var errRevisionNotFound = errors.New("revision not found")
+
+// ListRevisions returns the revisions of a note the user may edit, newest
+// first, paginated by ?page= and ?per_page=
+func (h *NoteHandler) ListRevisions(c *fiber.Ctx) error {
+	note, err := h.findEditableNote(c)
+	if err != nil {
+		return noteError(c, err)
+	}
+	params := h.list.ParseListParams(c.Queries())
+
+	query := h.db.WithContext(c.UserContext()).Model(&models.NoteRevision{}).Where("note_id = ?", note.ID)
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load revisions",
+		})
+	}
+
+	var revisions []models.NoteRevision
+	err = query.Preload("Editor").Order("number DESC").
+		Offset(params.Offset()).Limit(params.PerPage).
+		Find(&revisions).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load revisions",
+		})
+	}
+
+	data := make([]revisionSummary, len(revisions))
+	for i := range revisions {
+		data[i] = newRevisionSummary(&revisions[i])
+	}
+	return c.JSON(fiber.Map{
+		"data":     data,
+		"total":    total,
+		"page":     params.Page,
+		"per_page": params.PerPage,
+	})
+}
+
+// GetRevision returns the revision :number of a note the user may edit
+func (h *NoteHandler) GetRevision(c *fiber.Ctx) error {
+	note, err := h.findEditableNote(c)
+	if err != nil {
+		return noteError(c, err)
+	}
+	revision, err := h.findRevision(c, note, c.Params("number"))
+	if err != nil {
+		return revisionError(c, err)
+	}
+	return c.JSON(revisionResponse{
+		revisionSummary: newRevisionSummary(revision),
+		Content:         revision.Content,
+	})
+}
+
+// DiffRevisions compares the revisions ?from= and ?to= of a note the user may
+// edit. ?to= defaults to the latest revision and ?from= to the one before it.
+// ?mode=unified (the default) gives the content as a unified diff with
+// ?context= lines around the changes, ?mode=words as word level changes.
+func (h *NoteHandler) DiffRevisions(c *fiber.Ctx) error {
+	note, err := h.findEditableNote(c)
+	if err != nil {
+		return noteError(c, err)
+	}
+	mode := c.Query("mode", diffUnified)
+	if mode != diffUnified && mode != diffWords {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("mode must be %q or %q", diffUnified, diffWords),
+		})
+	}
+
+	to, err := h.findRevision(c, note, c.Query("to"))
+	if err != nil {
+		return revisionError(c, err)
+	}
+	fromNumber := c.Query("from")
+	if fromNumber == "" {
+		fromNumber = strconv.Itoa(max(to.Number-1, 1))
+	}
+	from, err := h.findRevision(c, note, fromNumber)
+	if err != nil {
+		return revisionError(c, err)
+	}
+
+	result := revisionDiff{
+		From:  newRevisionSummary(from),
+		To:    newRevisionSummary(to),
+		Mode:  mode,
+		Title: diff.Words(from.Title, to.Title),
+		Tags: tagChanges{
+			Added:   missingFrom(to.TagList(), from.TagList()),
+			Removed: missingFrom(from.TagList(), to.TagList()),
+		},
+	}
+	if mode == diffWords {
+		result.Content = diff.Words(from.Content, to.Content)
+	} else {
+		context := c.QueryInt("context", diff.DefaultContext)
+		unified := diff.Unified(from.Content, to.Content,
+			fmt.Sprintf("revision %d", from.Number), fmt.Sprintf("revision %d", to.Number), context)
+		result.Unified = &unified
+	}
+	return c.JSON(result)
+}
+
+// RestoreRevision brings a note the user may edit back to its revision
+// :number; the restored state is recorded as a new revision
+func (h *NoteHandler) RestoreRevision(c *fiber.Ctx) error {
+	note, err := h.findEditableNote(c)
+	if err != nil {
+		return noteError(c, err)
+	}
+	revision, err := h.findRevision(c, note, c.Params("number"))
+	if err != nil {
+		return revisionError(c, err)
+	}
+
+	note.Title, note.Content = revision.Title, revision.Content
+	if err := h.save(c, note, revision.TagList()); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to restore revision",
+		})
+	}
+
+	return c.JSON(newNoteResponse(note))
+}
+
+// findRevision loads a revision of note by number, the latest when number is
+// empty
+func (h *NoteHandler) findRevision(c *fiber.Ctx, note *models.Note, number string) (*models.NoteRevision, error) {
+	query := h.db.WithContext(c.UserContext()).Preload("Editor").Where("note_id = ?", note.ID)
+	if number == "" {
+		query = query.Order("number DESC")
+	} else {
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return nil, errRevisionNotFound
+		}
+		query = query.Where("number = ?", n)
+	}
+
+	var revision models.NoteRevision
+	if err := query.First(&revision).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errRevisionNotFound
+		}
+		return nil, err
+	}
+	return &revision, nil
+}
+
+func revisionError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, errRevisionNotFound) {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Revision not found",
+		})
+	}
+	return c.Status(500).JSON(fiber.Map{
+		"error": "Failed to load revision",
+	})
+}
+
+func newRevisionSummary(revision *models.NoteRevision) revisionSummary {
+	summary := revisionSummary{
+		ID:        revision.ID,
+		Number:    revision.Number,
+		Title:     revision.Title,
+		Tags:      revision.TagList(),
+		EditorID:  revision.EditorID,
+		CreatedAt: revision.CreatedAt,
+	}
+	if revision.Editor != nil {
+		summary.Editor = revision.Editor.Username
+	}
+	return summary
+}
+
+// missingFrom returns the values of a that are not in b
+func missingFrom(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, value := range b {
+		in[value] = true
+	}
+	missing := []string{}
+	for _, value := range a {
+		if !in[value] {
+			missing = append(missing, value)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,349 @@
+// handlers/note_shares.go
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mviner000/eyygo/admin"
+	"github.com/mviner000/eyygo/middleware"
+	"github.com/mviner000/eyygo/models"
+	"gorm.io/gorm"
+)
+
+// SharePasswordHeader carries the password of a share link
+const SharePasswordHeader = "X-Share-Password"
+
+// shareRequest shares a note with a user or a group
+type shareRequest struct {
+	UserID     *uint  `json:"user_id"`
+	GroupID    *uint  `json:"group_id"`
+	Permission string `json:"permission"` // viewer (default) or editor
+}
+
+// shareResponse is a share as returned by the API
+type shareResponse struct {
+	ID         uint      `json:"id"`
+	UserID     *uint     `json:"user_id"`
+	User       string    `json:"user,omitempty"` // username
+	GroupID    *uint     `json:"group_id"`
+	Group      string    `json:"group,omitempty"` // group name
+	Permission string    `json:"permission"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// linkRequest creates a share link; both fields are optional
+type linkRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+	Password  string     `json:"password"`
+}
+
+// linkResponse is a share link as returned by the API. Token and URL are
+// only known when the link is created.
+type linkResponse struct {
+	ID          uint       `json:"id"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	Expired     bool       `json:"expired"`
+	HasPassword bool       `json:"has_password"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Token       string     `json:"token,omitempty"`
+	URL         string     `json:"url,omitempty"`
+}
+
+// ListShares returns the users and groups a note the user owns is shared with
+func (h *NoteHandler) ListShares(c *fiber.Ctx) error {
+	note, err := h.findOwnedNote(c)
+	if err != nil {
+		return noteError(c, err)
+	}
+
+	var shares []models.NoteShare
+	err = h.db.WithContext(c.UserContext()).
+		Preload("User").Preload("Group").
+		Where("note_id = ?", note.ID).
+		Order("id").
+		Find(&shares).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load shares",
+		})
+	}
+
+	data := make([]shareResponse, len(shares))
+	for i := range shares {
+		data[i] = newShareResponse(&shares[i])
+	}
+	return c.JSON(fiber.Map{
+		"data": data,
+	})
+}
+
+// ShareNote shares a note the user owns with a user or a group, or changes
+// the permission of an existing share with them
+func (h *NoteHandler) ShareNote(c *fiber.Ctx) error {
+	note, err := h.findOwnedNote(c)
+	if err != nil {
+		return noteError(c, err)
+	}
+
+	var req shareRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	db := h.db.WithContext(middleware.AuditContext(c))
+	if err := validateShare(db, note, &req); err != nil {
+		return noteError(c, err)
+	}
+
+	share := models.NoteShare{NoteID: note.ID}
+	query := db.Where("note_id = ?", note.ID)
+	if req.UserID != nil {
+		query = query.Where("user_id = ?", *req.UserID)
+	} else {
+		query = query.Where("group_id = ?", *req.GroupID)
+	}
+	if err := query.Find(&share).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to share note",
+		})
+	}
+
+	status := 200
+	if share.ID == 0 {
+		status = 201
+	}
+	share.UserID, share.GroupID, share.Permission = req.UserID, req.GroupID, req.Permission
+	if err := db.Omit("Note", "User", "Group").Save(&share).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to share note",
+		})
+	}
+	db.Preload("User").Preload("Group").First(&share, share.ID)
+
+	return c.Status(status).JSON(newShareResponse(&share))
+}
+
+// UnshareNote removes the share :share of a note the user owns
+func (h *NoteHandler) UnshareNote(c *fiber.Ctx) error {
+	note, err := h.findOwnedNote(c)
+	if err != nil {
+		return noteError(c, err)
+	}
+
+	result := h.db.WithContext(c.UserContext()).
+		Where("note_id = ?", note.ID).
+		Delete(&models.NoteShare{}, paramID(c, "share"))
+	if result.Error != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to remove share",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Share not found",
+		})
+	}
+
+	return c.SendStatus(204)
+}
+
+// ListShareLinks returns the share links of a note the user owns
+func (h *NoteHandler) ListShareLinks(c *fiber.Ctx) error {
+	note, err := h.findOwnedNote(c)
+	if err != nil {
+		return noteError(c, err)
+	}
+
+	var links []models.ShareLink
+	if err := h.db.WithContext(c.UserContext()).Where("note_id = ?", note.ID).Order("id").Find(&links).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load share links",
+		})
+	}
+
+	now := time.Now()
+	data := make([]linkResponse, len(links))
+	for i := range links {
+		data[i] = newLinkResponse(&links[i], now)
+	}
+	return c.JSON(fiber.Map{
+		"data": data,
+	})
+}
+
+// CreateShareLink creates a link to read a note the user owns without an
+// account, optionally expiring at expires_at and protected by a password.
+// The response holds the token; it cannot be retrieved later.
+func (h *NoteHandler) CreateShareLink(c *fiber.Ctx) error {
+	note, err := h.findOwnedNote(c)
+	if err != nil {
+		return noteError(c, err)
+	}
+
+	var req linkRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid request body",
+			})
+		}
+	}
+	now := time.Now()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return noteError(c, admin.FieldErrors{"expires_at": "must be in the future"})
+	}
+
+	link := models.ShareLink{NoteID: note.ID, ExpiresAt: req.ExpiresAt}
+	if userID, ok := middleware.CurrentUserID(c); ok {
+		link.CreatedByID = &userID
+	}
+	token, err := models.NewShareLink(h.db.WithContext(c.UserContext()), &link, req.Password)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create share link",
+		})
+	}
+
+	response := newLinkResponse(&link, now)
+	response.Token = token
+	response.URL = c.BaseURL() + "/api/shared/" + token
+	return c.Status(201).JSON(response)
+}
+
+// DeleteShareLink revokes the share link :link of a note the user owns
+func (h *NoteHandler) DeleteShareLink(c *fiber.Ctx) error {
+	note, err := h.findOwnedNote(c)
+	if err != nil {
+		return noteError(c, err)
+	}
+
+	result := h.db.WithContext(c.UserContext()).
+		Where("note_id = ?", note.ID).
+		Delete(&models.ShareLink{}, paramID(c, "link"))
+	if result.Error != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to revoke share link",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Share link not found",
+		})
+	}
+
+	return c.SendStatus(204)
+}
+
+// GetSharedNote returns the note of the share link :token to anyone holding
+// it, with the link's password in the X-Share-Password header if it has one
+func (h *NoteHandler) GetSharedNote(c *fiber.Ctx) error {
+	db := h.db.WithContext(c.UserContext())
+	link, err := models.FindShareLink(db, c.Params("token"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Share link not found",
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load share link",
+		})
+	}
+	if link.Expired(time.Now()) {
+		return c.Status(410).JSON(fiber.Map{
+			"error": "Share link expired",
+		})
+	}
+	if password := c.Get(SharePasswordHeader); !link.CheckPassword(password) {
+		message := "Invalid password"
+		if password == "" {
+			message = "Password required"
+		}
+		return c.Status(401).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	var note models.Note
+	if err := db.Preload("Author").Preload("Tags").First(&note, link.NoteID).Error; err != nil {
+		return noteError(c, err)
+	}
+	return c.JSON(newNoteResponse(&note))
+}
+
+// validateShare checks the share targets an existing user other than the
+// author, or an existing group, and defaults its permission to viewer
+func validateShare(db *gorm.DB, note *models.Note, req *shareRequest) error {
+	errs := admin.FieldErrors{}
+	if req.Permission == "" {
+		req.Permission = models.ShareViewer
+	}
+	if req.Permission != models.ShareViewer && req.Permission != models.ShareEditor {
+		errs["permission"] = models.ErrSharePermission.Error()
+	}
+
+	switch {
+	case (req.UserID == nil) == (req.GroupID == nil):
+		errs["user_id"] = models.ErrShareTarget.Error()
+	case req.UserID != nil:
+		var count int64
+		if err := db.Model(&models.User{}).Where("id = ?", *req.UserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			errs["user_id"] = "no such user"
+		} else if *req.UserID == note.AuthorID {
+			errs["user_id"] = "the author already has access"
+		}
+	default:
+		var count int64
+		if err := db.Model(&models.Group{}).Where("id = ?", *req.GroupID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			errs["group_id"] = "no such group"
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func newShareResponse(share *models.NoteShare) shareResponse {
+	response := shareResponse{
+		ID:         share.ID,
+		UserID:     share.UserID,
+		GroupID:    share.GroupID,
+		Permission: share.Permission,
+		CreatedAt:  share.CreatedAt,
+	}
+	if share.User != nil {
+		response.User = share.User.Username
+	}
+	if share.Group != nil {
+		response.Group = share.Group.Name
+	}
+	return response
+}
+
+func newLinkResponse(link *models.ShareLink, now time.Time) linkResponse {
+	return linkResponse{
+		ID:          link.ID,
+		ExpiresAt:   link.ExpiresAt,
+		Expired:     link.Expired(now),
+		HasPassword: link.HasPassword(),
+		CreatedAt:   link.CreatedAt,
+	}
+}
+
+// paramID parses a numeric route parameter, zero (matching nothing) when invalid
+func paramID(c *fiber.Ctx, name string) uint {
+	id, _ := strconv.ParseUint(c.Params(name), 10, 64)
+	return uint(id)
+}
@@ -0,0 +1,473 @@
+// handlers/notes.go
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mviner000/eyygo/admin"
+	"github.com/mviner000/eyygo/middleware"
+	"github.com/mviner000/eyygo/models"
+	"github.com/mviner000/eyygo/search"
+	"gorm.io/gorm"
+)
+
+// NoteHandler serves /api/notes. Published notes are readable by everyone,
+// drafts only by their author and the users they are shared with; authors
+// and editors change notes, and only authors (or staff, who may change any)
+// publish, schedule, delete and share them.
+type NoteHandler struct {
+	db *gorm.DB
+
+	// list reuses the admin's search, filtering, ordering and pagination
+	list *admin.ModelAdmin
+}
+
+// noteRequest is the body of create and update; fields left out are unchanged.
+// IsPublished predates Status: true publishes and false returns to draft.
+type noteRequest struct {
+	Title       *string    `json:"title"`
+	Content     *string    `json:"content"`
+	Tags        []string   `json:"tags"`
+	Status      *string    `json:"status"`
+	PublishAt   *time.Time `json:"publish_at"` // when a scheduled note is published
+	IsPublished *bool      `json:"is_published"`
+}
+
+// noteResponse is a note as returned by the API
+type noteResponse struct {
+	ID          uint       `json:"id"`
+	Title       string     `json:"title"`
+	Content     string     `json:"content"`      // Markdown
+	ContentHTML string     `json:"content_html"` // Content rendered to sanitized HTML
+	Tags        []string   `json:"tags"`
+	Status      string     `json:"status"`
+	PublishAt   *time.Time `json:"publish_at"`
+	ArchivedAt  *time.Time `json:"archived_at"`
+	IsPublished bool       `json:"is_published"`
+	AuthorID    uint       `json:"author_id"`
+	Author      string     `json:"author,omitempty"` // username
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// searchResult is a note matching a search
+type searchResult struct {
+	noteResponse
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"` // HTML, with the matched terms in <mark>
+}
+
+func NewNoteHandler(db *gorm.DB) *NoteHandler {
+	return &NoteHandler{
+		db: db,
+		list: &admin.ModelAdmin{
+			Model:        &models.Note{},
+			SearchFields: []string{"Title", "Content"},
+			FilterFields: []string{"Status", "AuthorID"},
+			OrderFields:  []string{"CreatedAt", "UpdatedAt", "PublishAt", "Title"},
+			FormFields:   []string{"Title", "Content", "Status"},
+			Choices:      map[string][]admin.Choice{"Status": admin.NoteStatusChoices},
+			Preload:      []string{"Author", "Tags"},
+			DB:           db,
+		},
+	}
+}
+
+// ListNotes returns the visible notes, searchable with ?q=, filterable by
+// ?status=, ?author_id= and ?tag= and paginated like the admin lists.
+// Several tags (?tag=go&tag=web or ?tag=go,web) match notes carrying any of
+// them, or all of them with ?tag_match=all.
+func (h *NoteHandler) ListNotes(c *fiber.Ctx) error {
+	userID, _ := middleware.CurrentUserID(c)
+	params := h.list.ParseListParams(c.Queries())
+
+	query := h.db.WithContext(c.UserContext()).Model(&models.Note{}).
+		Scopes(models.VisibleNotes(userID, middleware.IsStaff(c)))
+	if tags := queryTags(c); len(tags) > 0 {
+		query = query.Scopes(models.TaggedNotes(tags, c.Query("tag_match") == "all"))
+	}
+	query, err := h.list.Filter(query, params)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	entries, response, fetchErr := fetchPage(c, h.list, query, params)
+	if fetchErr != nil {
+		return c.Status(fetchErr.Code).JSON(fiber.Map{
+			"error": fetchErr.Message,
+		})
+	}
+
+	notes := *entries.(*[]models.Note)
+	data := make([]noteResponse, len(notes))
+	for i := range notes {
+		data[i] = newNoteResponse(&notes[i])
+	}
+	response["data"] = data
+	return c.JSON(response)
+}
+
+// SearchNotes ranks the visible notes by full-text relevance to ?q=, with
+// highlighted snippets of their content, paginated by ?page= and ?per_page=
+func (h *NoteHandler) SearchNotes(c *fiber.Ctx) error {
+	q := c.Query("q")
+	if len(search.Terms(q)) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Query parameter q is required",
+		})
+	}
+	params := h.list.ParseListParams(c.Queries())
+	backend := search.Current()
+
+	userID, _ := middleware.CurrentUserID(c)
+	db := h.db.WithContext(c.UserContext())
+	query := db.Model(&models.Note{}).
+		Scopes(models.VisibleNotes(userID, middleware.IsStaff(c)), backend.Match(q))
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to search notes",
+		})
+	}
+
+	var hits []search.Hit
+	err := query.Scopes(backend.Rank(q)).
+		Offset(params.Offset()).Limit(params.PerPage).
+		Find(&hits).Error
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to search notes",
+		})
+	}
+
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	var notes []models.Note
+	if len(ids) > 0 {
+		if err := db.Preload("Author").Preload("Tags").Find(&notes, ids).Error; err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to search notes",
+			})
+		}
+	}
+	byID := make(map[uint]*models.Note, len(notes))
+	for i := range notes {
+		byID[notes[i].ID] = &notes[i]
+	}
+
+	results := make([]searchResult, 0, len(hits))
+	for _, hit := range hits {
+		if note, ok := byID[hit.ID]; ok {
+			results = append(results, searchResult{
+				noteResponse: newNoteResponse(note),
+				Rank:         hit.Rank,
+				Snippet:      search.Highlight(hit.Snippet, q),
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"data":     results,
+		"total":    total,
+		"page":     params.Page,
+		"per_page": params.PerPage,
+		"backend":  backend.Name(),
+	})
+}
+
+// GetNote returns a visible note
+func (h *NoteHandler) GetNote(c *fiber.Ctx) error {
+	note, err := h.findNote(c)
+	if err != nil {
+		return noteError(c, err)
+	}
+	return c.JSON(newNoteResponse(note))
+}
+
+// CreateNote creates a note authored by the current user
+func (h *NoteHandler) CreateNote(c *fiber.Ctx) error {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		return c.Status(401).JSON(fiber.Map{
+			"error": "Invalid or expired token",
+		})
+	}
+
+	var req noteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	note := &models.Note{AuthorID: userID}
+	if err := h.bind(c, note, req, true); err != nil {
+		return noteError(c, err)
+	}
+	db := h.db.WithContext(middleware.AuditContext(c))
+	err := db.Transaction(func(tx *gorm.DB) error {
+		tags, err := models.FindOrCreateTags(tx, req.Tags)
+		if err != nil {
+			return err
+		}
+		note.Tags = tags
+		return tx.Create(note).Error
+	})
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create note",
+		})
+	}
+	db.Preload("Author").First(note, note.ID)
+
+	return c.Status(201).JSON(newNoteResponse(note))
+}
+
+// UpdateNote changes the fields present in the body of a note the user may edit
+func (h *NoteHandler) UpdateNote(c *fiber.Ctx) error {
+	note, err := h.findEditableNote(c)
+	if err != nil {
+		return noteError(c, err)
+	}
+
+	var req noteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if err := h.bind(c, note, req, false); err != nil {
+		return noteError(c, err)
+	}
+	if err := h.save(c, note, req.Tags); err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update note",
+		})
+	}
+
+	return c.JSON(newNoteResponse(note))
+}
+
+// DeleteNote deletes a note the user owns
+func (h *NoteHandler) DeleteNote(c *fiber.Ctx) error {
+	note, err := h.findOwnedNote(c)
+	if err != nil {
+		return noteError(c, err)
+	}
+
+	if err := h.db.WithContext(c.UserContext()).Delete(note).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete note",
+		})
+	}
+
+	return c.SendStatus(204)
+}
+
+// ListTags returns the tags of the visible notes with their number of notes,
+// most used first. ?q= keeps the tags whose slug starts with it, for
+// autocompletion, and ?limit= caps their number.
+func (h *NoteHandler) ListTags(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit")
+	if limit <= 0 || limit > admin.MaxPerPage {
+		limit = admin.DefaultAutocompleteLimit
+	}
+
+	userID, _ := middleware.CurrentUserID(c)
+	db := h.db.WithContext(c.UserContext())
+	notes := db.Model(&models.Note{}).Scopes(models.VisibleNotes(userID, middleware.IsStaff(c)))
+	query := db.Model(&models.Tag{}).Scopes(models.CountedTags(notes))
+	if q := models.NormalizeTag(c.Query("q")); q != "" {
+		query = query.Where("tags.slug LIKE ?", q+"%")
+	}
+
+	tags := []models.TagCount{}
+	if err := query.Limit(limit).Find(&tags).Error; err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to load tags",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"data": tags,
+	})
+}
+
+var (
+	errNoteForbidden = errors.New("only the author, its editors or staff may change this note")
+	errNoteNotOwned  = errors.New("only the author or staff may do this")
+)
+
+// findNote loads the note of the :id parameter if the user may read it
+func (h *NoteHandler) findNote(c *fiber.Ctx) (*models.Note, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	userID, _ := middleware.CurrentUserID(c)
+	var note models.Note
+	err = h.db.WithContext(c.UserContext()).
+		Scopes(models.VisibleNotes(userID, middleware.IsStaff(c))).
+		Preload("Author").
+		Preload("Tags").
+		First(&note, uint(id)).Error
+	if err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// findEditableNote is findNote for notes the user may also change
+func (h *NoteHandler) findEditableNote(c *fiber.Ctx) (*models.Note, error) {
+	note, err := h.findNote(c)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, _ := middleware.CurrentUserID(c)
+	var editable int64
+	err = h.db.WithContext(c.UserContext()).Model(&models.Note{}).
+		Scopes(models.EditableNotes(userID, middleware.IsStaff(c))).
+		Where("id = ?", note.ID).
+		Count(&editable).Error
+	if err != nil {
+		return nil, err
+	}
+	if editable == 0 {
+		return nil, errNoteForbidden
+	}
+	return note, nil
+}
+
+// findOwnedNote is findNote for notes the user authored, or any for staff
+func (h *NoteHandler) findOwnedNote(c *fiber.Ctx) (*models.Note, error) {
+	note, err := h.findNote(c)
+	if err != nil {
+		return nil, err
+	}
+	if userID, _ := middleware.CurrentUserID(c); note.AuthorID != userID && !middleware.IsStaff(c) {
+		return nil, errNoteNotOwned
+	}
+	return note, nil
+}
+
+// save saves an existing note, with the given tags unless nil, recording a
+// revision attributed to the current user
+func (h *NoteHandler) save(c *fiber.Ctx, note *models.Note, tagNames []string) error {
+	return h.db.WithContext(middleware.AuditContext(c)).Transaction(func(tx *gorm.DB) error {
+		if tagNames != nil {
+			tags, err := models.FindOrCreateTags(tx, tagNames)
+			if err != nil {
+				return err
+			}
+			// Link the tags first and quietly, so that the revision recorded
+			// when saving the note has them
+			err = tx.Session(&gorm.Session{SkipHooks: true}).
+				Model(note).Omit("Tags.*").Association("Tags").Replace(tags)
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Omit("Author", "Tags").Save(note).Error
+	})
+}
+
+// bind validates the request like the admin forms and assigns it to note.
+// Editors may change the content of an existing note but not its status.
+func (h *NoteHandler) bind(c *fiber.Ctx, note *models.Note, req noteRequest, adding bool) error {
+	if !adding && (req.Status != nil || req.PublishAt != nil || req.IsPublished != nil) {
+		if userID, _ := middleware.CurrentUserID(c); note.AuthorID != userID && !middleware.IsStaff(c) {
+			return errNoteNotOwned
+		}
+	}
+
+	values := make(map[string]string)
+	if req.Title != nil {
+		values["title"] = *req.Title
+	}
+	if req.Content != nil {
+		values["content"] = *req.Content
+	}
+	if req.IsPublished != nil {
+		values["status"] = models.NoteDraft
+		if *req.IsPublished {
+			values["status"] = models.NotePublished
+		}
+	}
+	if req.Status != nil {
+		values["status"] = *req.Status
+	}
+	if err := h.list.SetValues(c.UserContext(), note, values, adding); err != nil {
+		return err
+	}
+	if req.PublishAt != nil {
+		note.PublishAt = req.PublishAt
+	}
+	if note.Status == models.NoteScheduled && note.PublishAt == nil {
+		return admin.FieldErrors{"Publish at": models.ErrPublishAtMissing.Error()}
+	}
+	return nil
+}
+
+// queryTags collects the tags of repeated or comma separated ?tag= parameters
+func queryTags(c *fiber.Ctx) []string {
+	var tags []string
+	for _, value := range c.Context().QueryArgs().PeekMulti("tag") {
+		tags = append(tags, strings.Split(string(value), ",")...)
+	}
+	return tags
+}
+
+func noteError(c *fiber.Ctx, err error) error {
+	var fieldErrors admin.FieldErrors
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Note not found",
+		})
+	case errors.Is(err, errNoteForbidden), errors.Is(err, errNoteNotOwned):
+		return c.Status(403).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	case errors.As(err, &fieldErrors):
+		return c.Status(422).JSON(fiber.Map{
+			"error":  "Invalid values",
+			"fields": fieldErrors,
+		})
+	}
+	return c.Status(500).JSON(fiber.Map{
+		"error": "Failed to load note",
+	})
+}
+
+func newNoteResponse(note *models.Note) noteResponse {
+	response := noteResponse{
+		ID:          note.ID,
+		Title:       note.Title,
+		Content:     note.Content,
+		ContentHTML: string(note.HTML()),
+		Tags:        note.TagList(),
+		Status:      note.Status,
+		PublishAt:   note.PublishAt,
+		ArchivedAt:  note.ArchivedAt,
+		IsPublished: note.IsPublished(),
+		AuthorID:    note.AuthorID,
+		CreatedAt:   note.CreatedAt,
+		UpdatedAt:   note.UpdatedAt,
+	}
+	if note.Author != nil {
+		response.Author = note.Author.Username
+	}
+	return response
+}
@@ -0,0 +1,153 @@
+// handlers/pagination.go
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mviner000/eyygo/admin"
+	"gorm.io/gorm"
+)
+
+// pageLink is one relation of an RFC 8288 Link header
+type pageLink struct {
+	Rel string
+	URL string
+}
+
+// pageURL returns the absolute URL of the request with some query parameters
+// replaced; empty values remove the parameter
+func pageURL(c *fiber.Ctx, overrides map[string]string) string {
+	values := url.Values{}
+	for key, value := range c.Queries() {
+		values.Set(key, value)
+	}
+	for key, value := range overrides {
+		if value == "" {
+			values.Del(key)
+		} else {
+			values.Set(key, value)
+		}
+	}
+	link := c.BaseURL() + c.Path()
+	if encoded := values.Encode(); encoded != "" {
+		link += "?" + encoded
+	}
+	return link
+}
+
+// setLinkHeader sets the Link header, e.g. `<...?page=3>; rel="next"`
+func setLinkHeader(c *fiber.Ctx, links []pageLink) {
+	if len(links) == 0 {
+		return
+	}
+	parts := make([]string, len(links))
+	for i, link := range links {
+		parts[i] = "<" + link.URL + `>; rel="` + link.Rel + `"`
+	}
+	c.Set("Link", strings.Join(parts, ", "))
+}
+
+// paginate responds with one page of query (filtered, not ordered) as
+// {"data": [...], ...}, without sensitive fields; see fetchPage
+func paginate(c *fiber.Ctx, modelAdmin *admin.ModelAdmin, query *gorm.DB, params admin.ListParams) error {
+	entries, response, err := fetchPage(c, modelAdmin, query, params)
+	if err != nil {
+		return c.Status(err.Code).JSON(fiber.Map{
+			"error": err.Message,
+		})
+	}
+	response["data"] = admin.Redact(entries)
+	return c.JSON(response)
+}
+
+// fetchPage loads one page of query (filtered, not ordered) and returns the
+// entries, as a pointer to a slice of the model, with the page metadata. With
+// ?cursor= the page is selected by keyset and the metadata carries
+// next_cursor/prev_cursor; otherwise by ?page=. The total is counted unless
+// ?count=false. Links to the neighbouring pages are set in a Link header.
+func fetchPage(c *fiber.Ctx, modelAdmin *admin.ModelAdmin, query *gorm.DB, params admin.ListParams) (interface{}, fiber.Map, *fiber.Error) {
+	response := fiber.Map{"per_page": params.PerPage}
+	var entries interface{}
+
+	var count int64
+	if !params.SkipCount {
+		if err := query.Session(&gorm.Session{}).Count(&count).Error; err != nil {
+			return nil, nil, fiber.NewError(500, "Failed to count entries")
+		}
+		response["total"] = count
+	}
+
+	var links []pageLink
+	if params.Keyset {
+		page, err := modelAdmin.KeysetPaginate(modelAdmin.Preloaded(query), params)
+		if errors.Is(err, admin.ErrInvalidCursor) {
+			return nil, nil, fiber.NewError(400, err.Error())
+		}
+		if err != nil {
+			return nil, nil, fiber.NewError(500, "Failed to fetch entries")
+		}
+
+		// The first page keeps an empty cursor, which selects keyset pagination
+		first := pageURL(c, map[string]string{admin.ParamCursor: ""})
+		if strings.Contains(first, "?") {
+			first += "&" + admin.ParamCursor + "="
+		} else {
+			first += "?" + admin.ParamCursor + "="
+		}
+		links = append(links, pageLink{Rel: "first", URL: first})
+		if page.Prev != "" {
+			links = append(links, pageLink{Rel: "prev", URL: pageURL(c, map[string]string{admin.ParamCursor: page.Prev})})
+		}
+		if page.Next != "" {
+			links = append(links, pageLink{Rel: "next", URL: pageURL(c, map[string]string{admin.ParamCursor: page.Next})})
+		}
+		entries = page.Entries
+		response["next_cursor"] = page.Next
+		response["prev_cursor"] = page.Prev
+	} else {
+		// One extra row tells whether a next page exists without counting
+		results := modelAdmin.NewSlice()
+		err := modelAdmin.Preloaded(modelAdmin.Order(query, params, false)).
+			Offset(params.Offset()).Limit(params.PerPage + 1).Find(results).Error
+		if err != nil {
+			return nil, nil, fiber.NewError(500, "Failed to fetch entries")
+		}
+		hasNext := trimPage(results, params.PerPage)
+
+		links = append(links, pageLink{Rel: "first", URL: pageURL(c, map[string]string{admin.ParamPage: ""})})
+		if params.Page > 1 {
+			links = append(links, pageLink{Rel: "prev", URL: pageURL(c, map[string]string{admin.ParamPage: strconv.Itoa(params.Page - 1)})})
+		}
+		if hasNext {
+			links = append(links, pageLink{Rel: "next", URL: pageURL(c, map[string]string{admin.ParamPage: strconv.Itoa(params.Page + 1)})})
+		}
+		if !params.SkipCount {
+			totalPages := (count + int64(params.PerPage) - 1) / int64(params.PerPage)
+			if totalPages > 1 {
+				links = append(links, pageLink{Rel: "last", URL: pageURL(c, map[string]string{admin.ParamPage: strconv.FormatInt(totalPages, 10)})})
+			}
+			response["total_pages"] = totalPages
+		}
+		entries = results
+		response["page"] = params.Page
+	}
+
+	setLinkHeader(c, links)
+	return entries, response, nil
+}
+
+// trimPage drops the extra row fetched beyond perPage from a slice pointer and
+// reports whether there was one
+func trimPage(results interface{}, perPage int) bool {
+	rows := reflect.ValueOf(results).Elem()
+	if rows.Len() <= perPage {
+		return false
+	}
+	rows.Set(rows.Slice(0, perPage))
+	return true
+}
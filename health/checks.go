@@ -0,0 +1,37 @@
+// health/checks.go
+package health
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// DiskSpace fails when the filesystem holding path has less than minFree bytes available
+func DiskSpace(path string, minFree uint64) CheckFunc {
+	return func(ctx context.Context) error {
+		free, err := freeDiskSpace(path)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %v", path, err)
+		}
+		if free < minFree {
+			return fmt.Errorf("only %d MB free on %s, need %d MB", free>>20, path, minFree>>20)
+		}
+		return nil
+	}
+}
+
+// Migrations fails when the table of any of the given models is missing
+func Migrations(db *gorm.DB, models ...interface{}) CheckFunc {
+	return func(ctx context.Context) error {
+		migrator := db.WithContext(ctx).Migrator()
+		for _, model := range models {
+			if !migrator.HasTable(model) {
+				return fmt.Errorf("table for %s has not been migrated", reflect.Indirect(reflect.ValueOf(model)).Type().Name())
+			}
+		}
+		return nil
+	}
+}
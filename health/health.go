@@ -0,0 +1,170 @@
+// health/health.go
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status is the outcome of a single check or of a whole report
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// DefaultTimeout bounds how long a single check may run
+const DefaultTimeout = 2 * time.Second
+
+// ErrShuttingDown is reported by readiness once the server has started draining
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// CheckFunc reports the health of one dependency. A nil error means healthy.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Report aggregates the results of every check of one kind
+type Report struct {
+	Status Status   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Healthy reports whether every check passed
+func (r Report) Healthy() bool {
+	return r.Status == StatusUp
+}
+
+// Registry holds the liveness and readiness checks of the application
+type Registry struct {
+	mu      sync.RWMutex
+	live    []check
+	ready   []check
+	Timeout time.Duration
+
+	shuttingDown atomic.Bool
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{Timeout: DefaultTimeout}
+}
+
+// RegisterLiveness adds a check that decides whether the process should be restarted
+func (r *Registry) RegisterLiveness(name string, fn CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.live = append(r.live, check{name: name, fn: fn})
+}
+
+// RegisterReadiness adds a check that decides whether the process should receive traffic
+func (r *Registry) RegisterReadiness(name string, fn CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ready = append(r.ready, check{name: name, fn: fn})
+}
+
+// Live runs all liveness checks
+func (r *Registry) Live(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := append([]check(nil), r.live...)
+	r.mu.RUnlock()
+	return r.run(ctx, checks)
+}
+
+// Ready runs all readiness checks. Once shutdown has begun it fails without
+// touching any dependency so the orchestrator stops routing traffic here.
+func (r *Registry) Ready(ctx context.Context) Report {
+	if r.shuttingDown.Load() {
+		return Report{
+			Status: StatusDown,
+			Checks: []Result{{Name: "shutdown", Status: StatusDown, Latency: "0s", Error: ErrShuttingDown.Error()}},
+		}
+	}
+
+	r.mu.RLock()
+	checks := append([]check(nil), r.ready...)
+	r.mu.RUnlock()
+	return r.run(ctx, checks)
+}
+
+// SetShuttingDown makes every subsequent readiness probe fail
+func (r *Registry) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// run executes the checks concurrently, each bounded by the registry timeout
+func (r *Registry) run(ctx context.Context, checks []check) Report {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c check) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c, timeout)
+		}(i, c)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+
+	report := Report{Status: StatusUp, Checks: results}
+	for _, result := range results {
+		if result.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, c check, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{
+		Name:    c.name,
+		Status:  StatusUp,
+		Latency: time.Since(start).Round(time.Microsecond).String(),
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,208 @@
+// logger/gorm.go
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fatih/color"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	queryCounterKey
+)
+
+// WithRequestID returns a context carrying the request ID, so queries run with it can be traced
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored by WithRequestID, or ""
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// WithQueryCounter returns a context that counts the queries run with it
+func WithQueryCounter(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryCounterKey, new(atomic.Int64))
+}
+
+// QueryCount returns the number of queries run with a context from WithQueryCounter
+func QueryCount(ctx context.Context) int64 {
+	if counter, ok := ctx.Value(queryCounterKey).(*atomic.Int64); ok {
+		return counter.Load()
+	}
+	return 0
+}
+
+// GormLogger writes GORM queries through the application logger. Every query is
+// logged at debug level, slow queries as warnings and failed queries as errors.
+type GormLogger struct {
+	logger        *Logger
+	level         gormlogger.LogLevel
+	SlowThreshold time.Duration // 0 disables slow query warnings
+	RedactFields  []string      // parameters bound to columns containing these names are hidden
+}
+
+// NewGormLogger creates a GORM logger adapter for l
+func NewGormLogger(l *Logger, slowThreshold time.Duration, redactFields []string) *GormLogger {
+	return &GormLogger{
+		logger:        l,
+		level:         gormlogger.Info,
+		SlowThreshold: slowThreshold,
+		RedactFields:  redactFields,
+	}
+}
+
+// LogMode implements gormlogger.Interface
+func (g *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	clone := *g
+	clone.level = level
+	return &clone
+}
+
+// Info implements gormlogger.Interface
+func (g *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if g.level >= gormlogger.Info {
+		g.logger.InfoLogger.Printf("%s %s", g.prefix(ctx), fmt.Sprintf(msg, args...))
+	}
+}
+
+// Warn implements gormlogger.Interface
+func (g *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if g.level >= gormlogger.Warn {
+		g.logger.WarningLogger.Printf("%s %s", g.prefix(ctx), fmt.Sprintf(msg, args...))
+	}
+}
+
+// Error implements gormlogger.Interface
+func (g *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if g.level >= gormlogger.Error {
+		g.logger.ErrorLogger.Printf("%s %s", g.prefix(ctx), fmt.Sprintf(msg, args...))
+	}
+}
+
+// Trace implements gormlogger.Interface. It also counts the query for the request.
+func (g *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if counter, ok := ctx.Value(queryCounterKey).(*atomic.Int64); ok {
+		counter.Add(1)
+	}
+	if g.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	yellow := color.New(color.FgYellow).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+
+	switch {
+	case err != nil && g.level >= gormlogger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		g.logger.ErrorLogger.Printf("%s %s error=%q %s", g.prefix(ctx), red("[SQL]"), err, fields(elapsed, rows, sql))
+	case g.SlowThreshold > 0 && elapsed > g.SlowThreshold && g.level >= gormlogger.Warn:
+		sql, rows := fc()
+		g.logger.WarningLogger.Printf("%s %s threshold=%s %s", g.prefix(ctx), yellow("[SLOW SQL]"), g.SlowThreshold, fields(elapsed, rows, sql))
+	case g.level >= gormlogger.Info:
+		sql, rows := fc()
+		g.logger.DebugLogger.Printf("%s [SQL] %s", g.prefix(ctx), fields(elapsed, rows, sql))
+	}
+}
+
+// ParamsFilter implements gorm.ParamsFilter and hides sensitive parameters before they are logged
+func (g *GormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, redactParams(sql, params, g.RedactFields)
+}
+
+func (g *GormLogger) prefix(ctx context.Context) string {
+	requestID := RequestIDFromContext(ctx)
+	if requestID == "" {
+		requestID = "-"
+	}
+	return fmt.Sprintf("[%s] request_id=%s", time.Now().Format("2006-01-02 15:04:05"), requestID)
+}
+
+func fields(elapsed time.Duration, rows int64, sql string) string {
+	rowsField := "-"
+	if rows >= 0 {
+		rowsField = strconv.FormatInt(rows, 10)
+	}
+	return fmt.Sprintf("duration=%s rows=%s sql=%q", elapsed.Round(time.Microsecond), rowsField, sql)
+}
+
+const redacted = "[REDACTED]"
+
+var (
+	placeholderPattern = regexp.MustCompile(`\?|\$(\d+)`)
+	comparedColumn     = regexp.MustCompile(`(?i)[\x60"\[]?(\w+)[\x60"\]]?\s*(?:=|<>|!=|<=|>=|<|>|\bLIKE)\s*$`)
+	insertColumns      = regexp.MustCompile(`(?is)^\s*INSERT\s+INTO\s+\S+\s*\(([^)]*)\)\s*VALUES`)
+)
+
+// redactParams replaces the parameters bound to sensitive columns. Columns are found
+// from INSERT column lists and from "column = ?" comparisons (SET and WHERE clauses).
+func redactParams(sql string, params []interface{}, sensitive []string) []interface{} {
+	if len(params) == 0 || len(sensitive) == 0 {
+		return params
+	}
+
+	var columns []string
+	valuesStart := -1
+	if match := insertColumns.FindStringSubmatchIndex(sql); match != nil {
+		for _, column := range strings.Split(sql[match[2]:match[3]], ",") {
+			columns = append(columns, strings.Trim(strings.TrimSpace(column), "`\"[]"))
+		}
+		valuesStart = match[1]
+	}
+
+	result := append([]interface{}(nil), params...)
+	position := 0
+	insertIndex := 0
+	for _, match := range placeholderPattern.FindAllStringSubmatchIndex(sql, -1) {
+		index := position
+		if match[2] >= 0 {
+			n, _ := strconv.Atoi(sql[match[2]:match[3]])
+			index = n - 1
+		}
+		position++
+		if index < 0 || index >= len(result) {
+			continue
+		}
+
+		column := ""
+		if valuesStart >= 0 && match[0] >= valuesStart && len(columns) > 0 {
+			column = columns[insertIndex%len(columns)]
+			insertIndex++
+		} else if m := comparedColumn.FindStringSubmatch(sql[:match[0]]); m != nil {
+			column = m[1]
+		}
+
+		if isSensitive(column, sensitive) {
+			result[index] = redacted
+		}
+	}
+	return result
+}
+
+func isSensitive(column string, sensitive []string) bool {
+	column = strings.ToLower(column)
+	if column == "" {
+		return false
+	}
+	for _, field := range sensitive {
+		if field != "" && strings.Contains(column, strings.ToLower(field)) {
+			return true
+		}
+	}
+	return false
+}
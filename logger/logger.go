@@ -1,9 +1,12 @@
 package logger
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"syscall"
 	"time"
 
 	"github.com/fatih/color"
@@ -11,6 +14,7 @@ import (
 )
 
 type Logger struct {
+	DebugLogger   *log.Logger
 	InfoLogger    *log.Logger
 	WarningLogger *log.Logger
 	ErrorLogger   *log.Logger
@@ -18,12 +22,42 @@ type Logger struct {
 
 func NewLogger() *Logger {
 	return &Logger{
+		DebugLogger:   log.New(io.Discard, "", 0),
 		InfoLogger:    log.New(os.Stdout, "", 0),
 		WarningLogger: log.New(os.Stdout, "", 0),
 		ErrorLogger:   log.New(os.Stderr, "", 0),
 	}
 }
 
+// Configure applies the logging settings: messages below level are discarded
+// and color output is disabled when useColor is false
+func (l *Logger) Configure(level string, useColor bool) {
+	color.NoColor = color.NoColor || !useColor
+
+	rank := map[string]int{"debug": 0, "info": 1, "warning": 2, "error": 3}[level]
+	output := func(minRank int, w io.Writer) io.Writer {
+		if rank > minRank {
+			return io.Discard
+		}
+		return w
+	}
+
+	l.DebugLogger.SetOutput(output(0, os.Stdout))
+	l.InfoLogger.SetOutput(output(1, os.Stdout))
+	l.WarningLogger.SetOutput(output(2, os.Stdout))
+}
+
+// Sync flushes any buffered output to the underlying files
+func (l *Logger) Sync() error {
+	if err := os.Stdout.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) {
+		return err
+	}
+	if err := os.Stderr.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) {
+		return err
+	}
+	return nil
+}
+
 // PrintServerStatus prints the server startup information similar to Django
 func (l *Logger) PrintServerStatus(host string, port string) {
 	cyan := color.New(color.FgCyan).SprintFunc()
@@ -71,8 +105,9 @@ func RequestLogger() fiber.Handler {
 		}
 
 		// Format the log message similar to Django
-		message := fmt.Sprintf("[%s] %s %s %s %s %s %s",
+		message := fmt.Sprintf("[%s] request_id=%s %s %s %s %s %s %s",
 			time.Now().Format("2006-01-02 15:04:05"),
+			RequestIDFromContext(c.UserContext()),
 			c.Method(),
 			statusColored,
 			c.Path(),
@@ -2,20 +2,32 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/template/html/v2"
+	"github.com/mviner000/eyygo/admin"
 	"github.com/mviner000/eyygo/config"
 	"github.com/mviner000/eyygo/handlers"
+	"github.com/mviner000/eyygo/health"
 	"github.com/mviner000/eyygo/logger"
 	"github.com/mviner000/eyygo/middleware"
 	"github.com/mviner000/eyygo/models"
 	"github.com/mviner000/eyygo/routes"
+	"github.com/mviner000/eyygo/scheduler"
+	"github.com/mviner000/eyygo/search"
 	"github.com/mviner000/eyygo/settings"
+	"github.com/mviner000/eyygo/shutdown"
 	"github.com/mviner000/eyygo/views"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 )
 
 // Global DB variable
@@ -26,16 +38,42 @@ func main() {
 	appLogger := logger.NewLogger()
 
 	// 1. First load configuration
-	cfg, err := config.LoadConfig()
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
 	if err != nil {
 		appLogger.ErrorLogger.Fatalf("Failed to load config: %v", err)
 	}
+	appLogger.Configure(cfg.Logging.Level, cfg.Logging.Color)
+
+	// Refuse insecure settings in production, warn about them otherwise
+	if issues := cfg.CheckSecurity(); len(issues) > 0 {
+		for _, issue := range issues {
+			if cfg.IsProduction() {
+				appLogger.ErrorLogger.Printf("ERROR: %s", issue)
+			} else {
+				appLogger.WarningLogger.Printf("WARNING: %s", issue)
+			}
+		}
+		if cfg.IsProduction() {
+			appLogger.ErrorLogger.Fatalf("Refusing to start in production with %d security issue(s)", len(issues))
+		}
+	}
 
-	// 2. Initialize database connection and store in global variable
-	DB, err = settings.NewDBConnection(cfg)
+	// 2. Initialize database connections and store the primary in global variable
+	// SQL goes through the app logger; every query is only traced at debug level
+	gormLogger := logger.NewGormLogger(appLogger, cfg.Logging.SlowQueryThreshold, cfg.Logging.RedactFields)
+	sqlLogLevel := gormlogger.Warn
+	if cfg.Logging.Level == "debug" {
+		sqlLogLevel = gormlogger.Info
+	}
+
+	databases, err := settings.OpenDatabases(cfg, gormLogger.LogMode(sqlLogLevel))
 	if err != nil {
 		appLogger.ErrorLogger.Fatalf("Failed to connect to database: %v", err)
 	}
+	DB = databases.Default()
 
 	// 3. Test database connection
 	sqlDB, err := DB.DB()
@@ -46,49 +84,135 @@ func main() {
 		appLogger.ErrorLogger.Fatalf("Failed to ping database: %v", err)
 	}
 
+	// Shutdown hooks run in reverse order: register what must close last first
+	shutdownManager := shutdown.NewManager()
+	shutdownManager.Register("logger", func(ctx context.Context) error {
+		return appLogger.Sync()
+	})
+	shutdownManager.Register("database", func(ctx context.Context) error {
+		return databases.Close()
+	})
+
 	// Initialize JWT secret
-	jwtSecret := []byte(os.Getenv("JWT_SECRET"))
-	if len(jwtSecret) == 0 {
-		jwtSecret = []byte("your-secret-key") // Default secret - change in production
-	}
+	jwtSecret := []byte(cfg.JWT.Secret)
 
 	// 4. Initialize handlers (after DB is initialized)
 	authHandler := handlers.NewAuthHandler(DB, jwtSecret)
+	authHandler.TokenExpiry = cfg.JWT.Expiry
+	authHandler.SecureCookies = cfg.IsProduction()
 	adminHandler := handlers.NewAdminHandler(DB)
+	adminHandler.Databases = databases
+	noteHandler := handlers.NewNoteHandler(DB)
 	viewHandler := views.NewViewHandler(DB)
+	viewHandler.Databases = databases
+
+	// Register health checks
+	healthRegistry := health.NewRegistry()
+	for _, name := range databases.Names() {
+		db, _ := databases.Using(name)
+		checkName := "database"
+		if name != config.DefaultDatabase {
+			checkName = "database:" + name
+		}
+		healthRegistry.RegisterReadiness(checkName, handlers.NewDBHandler(db).CheckHealth)
+	}
+	healthRegistry.RegisterReadiness("disk", health.DiskSpace(".", 100<<20)) // 100 MB
+	healthRegistry.RegisterReadiness("migrations", health.Migrations(DB, &models.User{}, &models.Note{}, &models.Tag{}, &models.NoteRevision{}, &models.Group{}, &models.GroupMember{}, &models.NoteShare{}, &models.ShareLink{}, &models.LogEntry{}))
+	healthHandler := handlers.NewHealthHandler(healthRegistry)
 
 	// 5. Auto-migrate the database
-	if err := DB.AutoMigrate(&models.User{}, &models.Note{}); err != nil {
+	if err := DB.AutoMigrate(&models.User{}, &models.Note{}, &models.Tag{}, &models.NoteRevision{}, &models.Group{}, &models.GroupMember{}, &models.NoteShare{}, &models.ShareLink{}, &models.LogEntry{}); err != nil {
 		appLogger.ErrorLogger.Printf("Failed to auto-migrate: %v", err)
 	}
+	if err := models.MigrateNoteTags(DB); err != nil {
+		appLogger.ErrorLogger.Printf("Failed to migrate note tags: %v", err)
+	}
+	if err := models.MigrateNoteStatus(DB); err != nil {
+		appLogger.ErrorLogger.Printf("Failed to migrate note statuses: %v", err)
+	}
+	if err := models.MigrateNoteRevisions(DB); err != nil {
+		appLogger.ErrorLogger.Printf("Failed to record first note revisions: %v", err)
+	}
+	if backend, err := search.Setup(DB); err != nil {
+		appLogger.WarningLogger.Printf("Full-text search unavailable, searching with %s: %v", backend.Name(), err)
+	}
+
+	// Background jobs; stopped before the database is closed
+	jobs := scheduler.New(cfg.Scheduler.Interval, appLogger.ErrorLogger)
+	jobs.Add("publish scheduled notes", func(ctx context.Context, now time.Time) error {
+		published, err := models.PublishDueNotes(DB.WithContext(ctx), now)
+		if published > 0 {
+			appLogger.InfoLogger.Printf("Published %d scheduled notes", published)
+		}
+		return err
+	})
+	jobs.Start()
+	shutdownManager.Register("scheduler", jobs.Stop)
+
+	// Register models with the admin site
+	admin.InitializeAdmin(DB)
 
-	// Initialize Fiber app
 	// Initialize Fiber app with template engine
+	engine := html.New(cfg.Templates.Dir, cfg.Templates.Ext)
+	engine.Reload(cfg.Templates.Reload)
+
 	app := fiber.New(fiber.Config{
-		Views: html.New("./templates", ".html"), // Set template engine
+		Views: engine, // Set template engine
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) && fiberErr.Code < fiber.StatusInternalServerError {
+				return c.Status(fiberErr.Code).SendString(fiberErr.Message)
+			}
 			appLogger.ErrorLogger.Printf("Error: %v", err)
 			return c.Status(500).SendString("Internal Server Error")
 		},
 	})
 
 	// Global Middleware
+	app.Use(middleware.RequestID())        // Request ID for log correlation
 	app.Use(middleware.XFrameOptions())    // Clickjacking protection
 	app.Use(middleware.ConfigureCORS(cfg)) // CORS with config
-	app.Use(middleware.RateLimit())        // Rate limiting
+	app.Use(middleware.RateLimit(cfg))     // Rate limiting
 	app.Use(middleware.SecurityHeaders())  // Additional security headers
 	app.Use(recover.New())                 // Recover from panics
 	app.Use(logger.RequestLogger())        // Request logging
 	app.Use(logger.ErrorLogger())          // Error logging
+	if cfg.Debug {
+		app.Use(middleware.QueryCount()) // X-Query-Count header to spot N+1 queries
+	}
 
 	// Setup routes with viewHandler
-	routes.SetupRoutes(app, authHandler, adminHandler, viewHandler, jwtSecret)
+	routes.SetupRoutes(app, authHandler, adminHandler, noteHandler, viewHandler, healthHandler, jwtSecret)
 
 	// Print server status (Django-style)
-	appLogger.PrintServerStatus(cfg.ServerHost, cfg.ServerPort)
+	appLogger.PrintServerStatus(cfg.Server.Host, cfg.Server.Port)
 
 	// Start server
-	if err := app.Listen(cfg.ServerHost + ":" + cfg.ServerPort); err != nil {
-		appLogger.ErrorLogger.Fatalf("Failed to start server: %v", err)
+	go func() {
+		if err := app.Listen(cfg.Server.Address()); err != nil {
+			appLogger.ErrorLogger.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Wait for an interrupt, then drain and release resources
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+	appLogger.InfoLogger.Printf("\nReceived %s, shutting down gracefully...", sig)
+
+	// Fail readiness first so the orchestrator stops routing new traffic
+	healthRegistry.SetShuttingDown()
+	time.Sleep(cfg.Shutdown.Delay)
+
+	if err := app.ShutdownWithTimeout(cfg.Shutdown.Timeout); err != nil {
+		appLogger.ErrorLogger.Printf("Failed to drain connections: %v", err)
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
+	defer cancel()
+	for _, err := range shutdownManager.Run(ctx) {
+		appLogger.ErrorLogger.Printf("Shutdown hook failed: %v", err)
+	}
+
+	appLogger.InfoLogger.Printf("Server stopped")
 }
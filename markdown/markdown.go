@@ -0,0 +1,48 @@
+// markdown/markdown.go
+
+// Package markdown renders user-written Markdown to HTML that is safe to
+// embed in pages: goldmark renders GitHub flavoured Markdown, then
+// bluemonday keeps only an allowlist of elements and attributes, so scripts,
+// event handlers and javascript: links never reach the browser.
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+var (
+	// Raw HTML is rendered too, as the policy sanitizes it with the rest
+	renderer = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(html.WithUnsafe()),
+	)
+	policy = newPolicy()
+)
+
+// newPolicy allows what user generated content needs: formatting, lists,
+// tables, images and links (with rel="nofollow"), plus task list checkboxes
+// and the language class of fenced code
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(bluemonday.SpaceSeparatedTokens).OnElements("code")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// Render converts Markdown to sanitized HTML
+func Render(source string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(policy.SanitizeBytes(buf.Bytes())), nil
+}
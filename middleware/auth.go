@@ -1,10 +1,21 @@
 package middleware
 
 import (
+	"context"
+	"net/url"
+
 	"github.com/gofiber/fiber/v2"
 	jwtware "github.com/gofiber/jwt/v3"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/mviner000/eyygo/models"
 )
 
+// AuthCookie is the cookie holding the JWT for browser sessions such as the admin
+const AuthCookie = "token"
+
+// AdminLoginURL is where AdminRequired sends anonymous visitors
+const AdminLoginURL = "/admin/login"
+
 // Protected creates a middleware that verifies JWT tokens
 func Protected(jwtSecret []byte) fiber.Handler {
 	return jwtware.New(jwtware.Config{
@@ -18,3 +29,106 @@ func jwtError(c *fiber.Ctx, err error) error {
 		"error": "Invalid or expired token",
 	})
 }
+
+// AdminRequired protects the server-rendered admin. The JWT is read from the
+// auth cookie (or the Authorization header) and must belong to a staff user;
+// everyone else is sent to the admin login page.
+func AdminRequired(jwtSecret []byte) fiber.Handler {
+	return jwtware.New(jwtware.Config{
+		SigningKey:   jwtSecret,
+		TokenLookup:  "cookie:" + AuthCookie + ",header:Authorization",
+		AuthScheme:   "Bearer",
+		ErrorHandler: redirectToAdminLogin,
+		SuccessHandler: func(c *fiber.Ctx) error {
+			claims := c.Locals("user").(*jwt.Token).Claims.(jwt.MapClaims)
+			if isStaff, _ := claims["is_staff"].(bool); !isStaff {
+				return redirectToAdminLogin(c, nil)
+			}
+			return c.Next()
+		},
+	})
+}
+
+func redirectToAdminLogin(c *fiber.Ctx, err error) error {
+	target := AdminLoginURL + "?next=" + url.QueryEscape(c.OriginalURL())
+
+	// HTMX follows HX-Redirect instead of swapping the login page into the target
+	if c.Get("HX-Request") == "true" {
+		c.Set("HX-Redirect", target)
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+	return c.Redirect(target)
+}
+
+// StaffRequired only lets through requests whose JWT has the is_staff claim,
+// the API counterpart of AdminRequired. It must run after Protected.
+func StaffRequired() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if IsStaff(c) {
+			return c.Next()
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Staff access required",
+		})
+	}
+}
+
+// SuperuserRequired only lets through requests whose JWT has the is_superuser
+// claim. It must run after Protected or AdminRequired.
+func SuperuserRequired() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, ok := c.Locals("user").(*jwt.Token)
+		if ok {
+			if claims, ok := token.Claims.(jwt.MapClaims); ok {
+				if isSuperuser, _ := claims["is_superuser"].(bool); isSuperuser {
+					return c.Next()
+				}
+			}
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Superuser access required",
+		})
+	}
+}
+
+// CurrentUserID returns the user ID from the verified JWT, if the request has one
+func CurrentUserID(c *fiber.Ctx) (uint, bool) {
+	token, ok := c.Locals("user").(*jwt.Token)
+	if !ok {
+		return 0, false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, false
+	}
+	id, ok := claims["id"].(float64)
+	if !ok || id <= 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// AuditContext is the request's context carrying the authenticated user, so
+// changes saved with it are attributed to them in the admin history and note
+// revisions
+func AuditContext(c *fiber.Ctx) context.Context {
+	ctx := c.UserContext()
+	if userID, ok := CurrentUserID(c); ok {
+		ctx = models.WithUserID(ctx, userID)
+	}
+	return ctx
+}
+
+// IsStaff reports whether the verified JWT has the is_staff claim
+func IsStaff(c *fiber.Ctx) bool {
+	token, ok := c.Locals("user").(*jwt.Token)
+	if !ok {
+		return false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return false
+	}
+	isStaff, _ := claims["is_staff"].(bool)
+	return isStaff
+}
@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"log"
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/mviner000/eyygo/config"
@@ -8,15 +11,35 @@ import (
 
 // ConfigureCORS returns CORS middleware with custom configuration
 func ConfigureCORS(cfg *config.Config) fiber.Handler {
-	// Determine allowed origins based on environment
-	allowedOrigins := "http://" + cfg.ServerHost + ":" + cfg.ServerPort
+	// Default to the server's own origin when none are configured
+	allowedOrigins := "http://" + cfg.Server.Address()
+	if len(cfg.CORS.AllowOrigins) > 0 {
+		allowedOrigins = strings.Join(cfg.CORS.AllowOrigins, ",")
+	}
+
+	// Browsers reject credentials for a wildcard origin and fiber refuses the
+	// combination, so the wildcard wins (see security.E004)
+	allowCredentials := cfg.CORS.AllowCredentials
+	if allowCredentials && allowsAnyOrigin(cfg.CORS.AllowOrigins) {
+		log.Printf("[WARNING] CORS_ALLOW_ORIGINS contains '*': CORS credentials are disabled")
+		allowCredentials = false
+	}
 
 	return cors.New(cors.Config{
 		AllowOrigins:     allowedOrigins,
-		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS,PATCH",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
-		AllowCredentials: true,
+		AllowMethods:     strings.Join(cfg.CORS.AllowMethods, ","),
+		AllowHeaders:     strings.Join(cfg.CORS.AllowHeaders, ", "),
+		AllowCredentials: allowCredentials,
 		ExposeHeaders:    "Content-Length",
-		MaxAge:           86400, // 24 hours
+		MaxAge:           int(cfg.CORS.MaxAge.Seconds()),
 	})
 }
+
+func allowsAnyOrigin(origins []string) bool {
+	for _, origin := range origins {
+		if strings.TrimSpace(origin) == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -1,17 +1,16 @@
 package middleware
 
 import (
-	"time"
-
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/mviner000/eyygo/config"
 )
 
 // RateLimit creates a rate limiting middleware
-func RateLimit() fiber.Handler {
+func RateLimit(cfg *config.Config) fiber.Handler {
 	return limiter.New(limiter.Config{
-		Max:        100,             // max requests
-		Expiration: 1 * time.Minute, // per minute
+		Max:        cfg.RateLimit.Max,        // max requests
+		Expiration: cfg.RateLimit.Expiration, // per window
 		KeyGenerator: func(c *fiber.Ctx) string {
 			return c.IP() // use IP address as key
 		},
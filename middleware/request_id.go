@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/mviner000/eyygo/logger"
+)
+
+// RequestID reuses the client's X-Request-ID or generates one, echoes it in the
+// response and stores it in the user context so database queries can be traced
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID := c.Get(fiber.HeaderXRequestID)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = utils.UUIDv4()
+		}
+
+		c.Set(fiber.HeaderXRequestID, requestID)
+		c.Locals("requestid", requestID)
+		c.SetUserContext(logger.WithRequestID(c.UserContext(), requestID))
+
+		return c.Next()
+	}
+}
+
+// QueryCount reports how many database queries served the request in an
+// X-Query-Count header. Meant for development, to spot N+1 query patterns.
+func QueryCount() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := logger.WithQueryCounter(c.UserContext())
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		c.Set("X-Query-Count", strconv.FormatInt(logger.QueryCount(ctx), 10))
+		return err
+	}
+}
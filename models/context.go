@@ -0,0 +1,20 @@
+// models/context.go
+package models
+
+import "context"
+
+type contextKey int
+
+const userIDKey contextKey = iota
+
+// WithUserID returns a context carrying the acting user, so changes made with
+// it are attributed to them (audit log entries, note revisions)
+func WithUserID(ctx context.Context, userID uint) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// UserIDFromContext returns the user stored by WithUserID
+func UserIDFromContext(ctx context.Context) (uint, bool) {
+	userID, ok := ctx.Value(userIDKey).(uint)
+	return userID, ok
+}
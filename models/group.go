@@ -0,0 +1,25 @@
+// models/group.go
+package models
+
+import "time"
+
+// Group is a named set of users that notes can be shared with
+type Group struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Name string `gorm:"uniqueIndex;size:80;not null" json:"name"`
+
+	Members []GroupMember `json:",omitempty"`
+}
+
+// GroupMember puts a user in a group
+type GroupMember struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	GroupID uint   `gorm:"not null;uniqueIndex:idx_group_members_user" json:"group_id"`
+	Group   *Group `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	UserID  uint   `gorm:"not null;uniqueIndex:idx_group_members_user;index" json:"user_id"`
+	User    *User  `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
@@ -0,0 +1,42 @@
+// models/log_entry.go
+package models
+
+import (
+	"time"
+)
+
+// Admin change actions recorded in LogEntry.Action
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+// LogEntry records a change made through the admin, for auditing
+type LogEntry struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	ActionTime time.Time `gorm:"not null;index" json:"action_time"`
+
+	UserID *uint `gorm:"index" json:"user_id"`
+	User   *User `gorm:"foreignKey:UserID;constraint:OnDelete:SET NULL" json:"-"`
+
+	Model    string `gorm:"size:100;not null;index:idx_log_entries_object" json:"model"`
+	ObjectID string `gorm:"size:64;not null;index:idx_log_entries_object" json:"object_id"`
+	Action   string `gorm:"size:10;not null" json:"action"`
+
+	// Changes is a JSON object of changed columns: {"title": {"old": "a", "new": "b"}}
+	Changes JSONText `gorm:"type:text" json:"changes"`
+}
+
+// JSONText is a JSON document stored in a text column; it is encoded as-is
+type JSONText string
+
+// MarshalJSON embeds the document instead of quoting it
+func (j JSONText) MarshalJSON() ([]byte, error) {
+	if j == "" {
+		return []byte("null"), nil
+	}
+	return []byte(j), nil
+}
@@ -0,0 +1,118 @@
+// models/migrations.go
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MigrateNoteTags moves the tags of notes stored in the former comma
+// separated notes.tags column into Tag rows, then drops the column. It does
+// nothing once the column is gone, so it is safe to run on every start after
+// AutoMigrate. A tag no slug can be made of, e.g. "!!!", fails the migration
+// before anything is changed.
+func MigrateNoteTags(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&Note{}, "tags") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Soft-deleted notes keep their tags too, for when they are restored
+		var rows []struct {
+			ID   uint
+			Tags string
+		}
+		err := tx.Table("notes").Select("id", "tags").
+			Where("tags IS NOT NULL AND tags <> ''").
+			Find(&rows).Error
+		if err != nil {
+			return err
+		}
+
+		// Fail rather than lose a tag without a slug, as long as the
+		// column is still there
+		for _, row := range rows {
+			for _, name := range strings.Split(row.Tags, ",") {
+				if strings.TrimSpace(name) != "" && NormalizeTag(name) == "" {
+					return fmt.Errorf("note %d: tag %q has no letters or digits to make a slug of", row.ID, strings.TrimSpace(name))
+				}
+			}
+		}
+
+		// Drop the column before linking: SQLite drops it by copying the
+		// table, which the foreign keys of note_tags would otherwise prevent
+		if err := tx.Migrator().DropColumn(&Note{}, "tags"); err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			tags, err := FindOrCreateTags(tx, strings.Split(row.Tags, ","))
+			if err != nil {
+				return err
+			}
+			if len(tags) == 0 {
+				continue
+			}
+			links := make([]map[string]interface{}, len(tags))
+			for i, tag := range tags {
+				links[i] = map[string]interface{}{"note_id": row.ID, "tag_id": tag.ID}
+			}
+			err = tx.Table("note_tags").Clauses(clause.OnConflict{DoNothing: true}).Create(links).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// MigrateNoteRevisions gives the notes saved before revisions were recorded
+// a first revision with their current state, so their history starts there.
+// Notes that have revisions are skipped, so it is safe to run on every start.
+func MigrateNoteRevisions(db *gorm.DB) error {
+	var notes []Note
+	err := db.Unscoped().
+		Where("id NOT IN (?)", db.Model(&NoteRevision{}).Select("note_id")).
+		Find(&notes).Error
+	if err != nil || len(notes) == 0 {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for i := range notes {
+			if err := recordRevision(tx, &notes[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateNoteStatus maps the former notes.is_published flag to Status:
+// published notes become published as of their last update, the others stay
+// drafts. The column is dropped afterwards, so it does nothing on later starts.
+func MigrateNoteStatus(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&Note{}, "is_published") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Session(&gorm.Session{SkipHooks: true}).Model(&Note{}).Unscoped().
+			Where("is_published = ?", true).
+			Updates(map[string]interface{}{
+				"status":     NotePublished,
+				"publish_at": gorm.Expr("COALESCE(publish_at, updated_at)"),
+				"updated_at": gorm.Expr("updated_at"),
+			}).Error
+		if err != nil {
+			return err
+		}
+		// ALTER TABLE rather than Migrator().DropColumn, which on SQLite
+		// copies the table and trips the foreign keys pointing at notes
+		return tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: "notes"}, clause.Column{Name: "is_published"}).Error
+	})
+}
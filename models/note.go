@@ -1,9 +1,32 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"html/template"
 	"time"
 
+	"github.com/mviner000/eyygo/markdown"
+	"github.com/mviner000/eyygo/search"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Lifecycle states of a note; only published notes are public
+const (
+	NoteDraft     = "draft"
+	NoteScheduled = "scheduled" // published by the scheduler at PublishAt
+	NotePublished = "published"
+	NoteArchived  = "archived"
+)
+
+// NoteStatuses lists the valid Note.Status values
+var NoteStatuses = []string{NoteDraft, NoteScheduled, NotePublished, NoteArchived}
+
+var (
+	ErrNoteStatus       = errors.New("status must be draft, scheduled, published or archived")
+	ErrPublishAtMissing = errors.New("a scheduled note needs a publish time")
 )
 
 type Note struct {
@@ -12,10 +35,195 @@ type Note struct {
 	UpdatedAt time.Time
 	DeletedAt gorm.DeletedAt `gorm:"index"`
 
-	Title       string `gorm:"size:200;not null"`
-	Content     string `gorm:"type:text"`
-	Author      *User  `gorm:"foreignkey:AuthorID"`
-	AuthorID    uint
-	IsPublished bool   `gorm:"default:false"`
-	Tags        string `gorm:"size:500"`
+	Title    string `gorm:"size:200;not null"`
+	Content  string `gorm:"type:text"` // Markdown
+	Author   *User  `gorm:"foreignkey:AuthorID"`
+	AuthorID uint
+	Tags     []Tag `gorm:"many2many:note_tags" json:",omitempty"`
+
+	// Status is one of NoteStatuses. PublishAt is when a scheduled note is
+	// due, or when a published one was; ArchivedAt when it was archived.
+	Status     string `gorm:"size:10;not null;default:draft;index"`
+	PublishAt  *time.Time
+	ArchivedAt *time.Time
+
+	Shares []NoteShare `json:",omitempty"`
+
+	// Content rendered by BeforeSave, and the hash of the Content it was
+	// rendered from; see HTML
+	ContentHTML string `gorm:"type:text" json:"-"`
+	ContentHash string `gorm:"size:64" json:"-"`
+}
+
+// HTML returns the Content rendered from Markdown to sanitized HTML. The
+// rendering cached in ContentHTML is used while Content is unchanged.
+func (n *Note) HTML() template.HTML {
+	if n.ContentHash == contentHash(n.Content) {
+		return template.HTML(n.ContentHTML)
+	}
+	rendered, err := markdown.Render(n.Content)
+	if err != nil {
+		return template.HTML(template.HTMLEscapeString(n.Content))
+	}
+	return rendered
+}
+
+// IsPublished reports whether the note is public
+func (n *Note) IsPublished() bool {
+	return n.Status == NotePublished
+}
+
+// BeforeSave keeps the lifecycle timestamps consistent with the status and
+// caches the rendered Content when it changed
+func (n *Note) BeforeSave(tx *gorm.DB) error {
+	if err := n.settleStatus(time.Now()); err != nil {
+		return err
+	}
+	hash := contentHash(n.Content)
+	if hash == n.ContentHash {
+		return nil
+	}
+	rendered, err := markdown.Render(n.Content)
+	if err != nil {
+		return err
+	}
+	n.ContentHTML, n.ContentHash = string(rendered), hash
+	return nil
+}
+
+// settleStatus validates the status and sets the timestamps it implies: a
+// scheduled note that is already due is published, a published one gets its
+// publication time and an archived one its archiving time. The timestamps are
+// kept in UTC, so that SQLite, which compares them as text, orders them right.
+func (n *Note) settleStatus(now time.Time) error {
+	if n.Status == "" {
+		n.Status = NoteDraft
+	}
+	now = now.UTC()
+	n.PublishAt, n.ArchivedAt = utcTime(n.PublishAt), utcTime(n.ArchivedAt)
+	if n.Status == NoteScheduled {
+		if n.PublishAt == nil {
+			return ErrPublishAtMissing
+		}
+		if !n.PublishAt.After(now) {
+			n.Status = NotePublished
+		}
+	}
+
+	switch n.Status {
+	case NoteDraft:
+		n.PublishAt, n.ArchivedAt = nil, nil
+	case NoteScheduled:
+		n.ArchivedAt = nil
+	case NotePublished:
+		if n.PublishAt == nil || n.PublishAt.After(now) {
+			n.PublishAt = &now
+		}
+		n.ArchivedAt = nil
+	case NoteArchived:
+		if n.ArchivedAt == nil {
+			n.ArchivedAt = &now
+		}
+	default:
+		return ErrNoteStatus
+	}
+	return nil
+}
+
+// PublishDueNotes publishes the scheduled notes whose time has come at now
+// and returns how many. It is a single conditional update, so schedulers of
+// several server processes may run it concurrently. now is compared in UTC,
+// like PublishAt is stored.
+func PublishDueNotes(db *gorm.DB, now time.Time) (int64, error) {
+	result := db.Session(&gorm.Session{SkipHooks: true}).
+		Model(&Note{}).
+		Where("status = ? AND publish_at <= ?", NoteScheduled, now.UTC()).
+		Update("status", NotePublished)
+	return result.RowsAffected, result.Error
+}
+
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// TagList returns the slugs of the loaded tags
+func (n *Note) TagList() []string {
+	slugs := make([]string, len(n.Tags))
+	for i, tag := range n.Tags {
+		slugs[i] = tag.Slug
+	}
+	return slugs
+}
+
+// VisibleNotes scopes a query to the notes a user may read: published notes,
+// their own drafts and the notes shared with them, or every note for staff
+func VisibleNotes(userID uint, isStaff bool) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if isStaff {
+			return db
+		}
+		published := clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "status"}, Value: NotePublished}
+		if userID == 0 {
+			return db.Where(published)
+		}
+		return db.Where(clause.Or(
+			published,
+			clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "author_id"}, Value: userID},
+			sharedWith(sharedNoteIDs(db, userID, SharePermissions...)),
+		))
+	}
+}
+
+// BeforeDelete removes the tag links, revisions and shares of a note deleted
+// permanently; soft deleted notes keep them for when they are restored
+func (n *Note) BeforeDelete(tx *gorm.DB) error {
+	if !tx.Statement.Unscoped || n.ID == 0 {
+		return nil
+	}
+	db := tx.Session(&gorm.Session{NewDB: true})
+	if err := db.Exec("DELETE FROM note_tags WHERE note_id = ?", n.ID).Error; err != nil {
+		return err
+	}
+	for _, model := range []interface{}{&NoteRevision{}, &NoteShare{}, &ShareLink{}} {
+		if err := db.Where("note_id = ?", n.ID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AfterSave keeps the search index in sync, restoring a soft-deleted note
+// indexes it again, and records a revision when the whole note was saved.
+// Updates of single columns, e.g. publishing, cannot change its text.
+func (n *Note) AfterSave(tx *gorm.DB) error {
+	if n.ID == 0 {
+		return nil
+	}
+	if n.DeletedAt.Valid {
+		return search.Remove(tx, n.ID)
+	}
+	if err := search.Index(tx, n.ID, n.Title, n.Content); err != nil {
+		return err
+	}
+	if _, whole := tx.Statement.Dest.(*Note); !whole {
+		return nil
+	}
+	return recordRevision(tx, n)
+}
+
+// AfterDelete removes the note from the search index
+func (n *Note) AfterDelete(tx *gorm.DB) error {
+	if n.ID == 0 {
+		return nil
+	}
+	return search.Remove(tx, n.ID)
 }
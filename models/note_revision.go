@@ -0,0 +1,77 @@
+// models/note_revision.go
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NoteRevision is a snapshot of a note taken each time it is saved; Number
+// counts the revisions of a note from 1
+type NoteRevision struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	NoteID uint `gorm:"not null;uniqueIndex:idx_note_revisions_number" json:"note_id"`
+	Number int  `gorm:"not null;uniqueIndex:idx_note_revisions_number" json:"number"`
+
+	Title   string `gorm:"size:200;not null" json:"title"`
+	Content string `gorm:"type:text" json:"content"`
+	Tags    string `gorm:"type:text" json:"-"` // comma separated slugs, see TagList
+
+	// EditorID is nil when the editor is unknown, e.g. for changes made
+	// outside a request
+	EditorID *uint `gorm:"index" json:"editor_id"`
+	Editor   *User `gorm:"foreignKey:EditorID;constraint:OnDelete:SET NULL" json:"-"`
+}
+
+// TagList returns the slugs of the tags the note had
+func (r *NoteRevision) TagList() []string {
+	if r.Tags == "" {
+		return []string{}
+	}
+	return strings.Split(r.Tags, ",")
+}
+
+// sameText reports whether two revisions have the same title, content and tags
+func (r *NoteRevision) sameText(other *NoteRevision) bool {
+	return r.Title == other.Title && r.Content == other.Content && r.Tags == other.Tags
+}
+
+// recordRevision snapshots the saved state of a note, tags as stored, unless
+// it is unchanged since the last revision. The editor is read from the
+// context of tx (see WithUserID).
+func recordRevision(tx *gorm.DB, note *Note) error {
+	db := tx.Session(&gorm.Session{NewDB: true})
+
+	var tags []string
+	err := db.Table("tags").
+		Joins("JOIN note_tags ON note_tags.tag_id = tags.id").
+		Where("note_tags.note_id = ?", note.ID).
+		Order("tags.slug").
+		Pluck("tags.slug", &tags).Error
+	if err != nil {
+		return err
+	}
+	revision := NoteRevision{
+		NoteID:  note.ID,
+		Title:   note.Title,
+		Content: note.Content,
+		Tags:    strings.Join(tags, ","),
+	}
+
+	var last NoteRevision
+	if err := db.Where("note_id = ?", note.ID).Order("number DESC").Limit(1).Find(&last).Error; err != nil {
+		return err
+	}
+	if last.ID != 0 && last.sameText(&revision) {
+		return nil
+	}
+	revision.Number = last.Number + 1
+	if userID, ok := UserIDFromContext(tx.Statement.Context); ok {
+		revision.EditorID = &userID
+	}
+	return db.Create(&revision).Error
+}
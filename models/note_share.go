@@ -0,0 +1,157 @@
+// models/note_share.go
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Permissions a note is shared with
+const (
+	ShareViewer = "viewer" // may read the note
+	ShareEditor = "editor" // may also change it
+)
+
+// SharePermissions lists the valid NoteShare.Permission values
+var SharePermissions = []string{ShareViewer, ShareEditor}
+
+var (
+	ErrShareTarget     = errors.New("a note is shared with either a user or a group")
+	ErrSharePermission = errors.New("permission must be viewer or editor")
+)
+
+// NoteShare gives a user, or every member of a group, access to a note
+type NoteShare struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	NoteID uint  `gorm:"not null;uniqueIndex:idx_note_shares_user;uniqueIndex:idx_note_shares_group" json:"note_id"`
+	Note   *Note `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+
+	UserID  *uint  `gorm:"uniqueIndex:idx_note_shares_user;index" json:"user_id"`
+	User    *User  `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	GroupID *uint  `gorm:"uniqueIndex:idx_note_shares_group;index" json:"group_id"`
+	Group   *Group `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+
+	Permission string `gorm:"size:10;not null;default:viewer" json:"permission"`
+}
+
+// BeforeSave checks the share has a single target and a known permission
+func (s *NoteShare) BeforeSave(tx *gorm.DB) error {
+	if (s.UserID == nil) == (s.GroupID == nil) {
+		return ErrShareTarget
+	}
+	if s.Permission == "" {
+		s.Permission = ShareViewer
+	}
+	if s.Permission != ShareViewer && s.Permission != ShareEditor {
+		return ErrSharePermission
+	}
+	return nil
+}
+
+// sharedNoteIDs selects the notes shared with a user, directly or through
+// one of their groups, with one of the permissions
+func sharedNoteIDs(db *gorm.DB, userID uint, permissions ...string) *gorm.DB {
+	db = db.Session(&gorm.Session{NewDB: true})
+	groupIDs := db.Model(&GroupMember{}).Select("group_id").Where("user_id = ?", userID)
+	return db.Model(&NoteShare{}).Select("note_id").
+		Where("permission IN ?", permissions).
+		Where(db.Where("user_id = ?", userID).Or("group_id IN (?)", groupIDs))
+}
+
+// sharedWith is the condition on notes of being among noteIDs
+func sharedWith(noteIDs *gorm.DB) clause.Expression {
+	return clause.Expr{
+		SQL:  "? IN (?)",
+		Vars: []interface{}{clause.Column{Table: clause.CurrentTable, Name: "id"}, noteIDs},
+	}
+}
+
+// EditableNotes scopes a query to the notes a user may change: their own and
+// those shared with them as editor, or every note for staff
+func EditableNotes(userID uint, isStaff bool) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if isStaff {
+			return db
+		}
+		return db.Where(clause.Or(
+			clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "author_id"}, Value: userID},
+			sharedWith(sharedNoteIDs(db, userID, ShareEditor)),
+		))
+	}
+}
+
+// ShareLink lets anyone holding its token read a note, until it expires and
+// with its password if it has one. Only a hash of the token is stored.
+type ShareLink struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	NoteID uint  `gorm:"not null;index" json:"note_id"`
+	Note   *Note `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+
+	TokenHash string     `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	Password  string     `gorm:"size:255" json:"-"` // bcrypt hash, empty for none
+	ExpiresAt *time.Time `json:"expires_at"`
+
+	CreatedByID *uint `json:"created_by_id"`
+	CreatedBy   *User `gorm:"foreignKey:CreatedByID;constraint:OnDelete:SET NULL" json:"-"`
+}
+
+// NewShareLink creates a link to a note and returns it with its token, which
+// cannot be recovered afterwards
+func NewShareLink(db *gorm.DB, link *ShareLink, password string) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	link.TokenHash = shareTokenHash(token)
+
+	if password != "" {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return "", err
+		}
+		link.Password = string(hashed)
+	}
+	return token, db.Create(link).Error
+}
+
+// FindShareLink returns the link with the given token, expired or not
+func FindShareLink(db *gorm.DB, token string) (*ShareLink, error) {
+	var link ShareLink
+	if err := db.Where("token_hash = ?", shareTokenHash(token)).First(&link).Error; err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// HasPassword reports whether the link asks for a password
+func (l *ShareLink) HasPassword() bool {
+	return l.Password != ""
+}
+
+// CheckPassword verifies the password of the link
+func (l *ShareLink) CheckPassword(password string) bool {
+	return !l.HasPassword() || bcrypt.CompareHashAndPassword([]byte(l.Password), []byte(password)) == nil
+}
+
+// Expired reports whether the link stopped working at now
+func (l *ShareLink) Expired(now time.Time) bool {
+	return l.ExpiresAt != nil && !now.Before(*l.ExpiresAt)
+}
+
+func shareTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,132 @@
+// models/tag.go
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tag labels notes; Slug is the normalized form notes are filtered by
+type Tag struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Name string `gorm:"size:50;not null" json:"name"`
+	Slug string `gorm:"uniqueIndex;size:50;not null" json:"slug"`
+
+	Notes []Note `gorm:"many2many:note_tags" json:",omitempty"`
+}
+
+// TagCount is a tag with the number of notes carrying it
+type TagCount struct {
+	Tag
+	Count int64 `gorm:"column:note_count" json:"count"`
+}
+
+var slugInvalid = regexp.MustCompile(`[^\p{L}\p{M}\p{N}-]+`)
+
+// NormalizeTag turns a tag name into its slug: lowercase, with anything but
+// letters of any script, digits and dashes turned into dashes, e.g.
+// "Go Lang" -> "go-lang" and "Café Crème" -> "café-crème"
+func NormalizeTag(name string) string {
+	return strings.Trim(slugInvalid.ReplaceAllString(strings.ToLower(strings.TrimSpace(name)), "-"), "-")
+}
+
+// TagSlugs normalizes and deduplicates tag names, dropping empty ones
+func TagSlugs(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	var slugs []string
+	for _, name := range names {
+		if slug := NormalizeTag(name); slug != "" && !seen[slug] {
+			seen[slug] = true
+			slugs = append(slugs, slug)
+		}
+	}
+	return slugs
+}
+
+// BeforeSave derives the slug from the name
+func (t *Tag) BeforeSave(tx *gorm.DB) error {
+	t.Name = strings.TrimSpace(t.Name)
+	t.Slug = NormalizeTag(t.Name)
+	if t.Slug == "" {
+		return gorm.ErrInvalidValue
+	}
+	return nil
+}
+
+// BeforeDelete removes the links of a tag to its notes
+func (t *Tag) BeforeDelete(tx *gorm.DB) error {
+	if t.ID == 0 {
+		return nil
+	}
+	return tx.Session(&gorm.Session{NewDB: true}).
+		Exec("DELETE FROM note_tags WHERE tag_id = ?", t.ID).Error
+}
+
+// FindOrCreateTags returns the tags with the given names, creating the
+// missing ones. Names with the same slug are the same tag.
+func FindOrCreateTags(db *gorm.DB, names []string) ([]Tag, error) {
+	tags := []Tag{}
+	for _, name := range names {
+		slug := NormalizeTag(name)
+		if slug == "" || containsTag(tags, slug) {
+			continue
+		}
+		tag := Tag{Name: strings.TrimSpace(name)}
+		if err := db.Where(Tag{Slug: slug}).FirstOrCreate(&tag).Error; err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func containsTag(tags []Tag, slug string) bool {
+	for _, tag := range tags {
+		if tag.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// TaggedNotes scopes a query on notes to those carrying any of the tags, or
+// all of them when all is set
+func TaggedNotes(names []string, all bool) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		slugs := TagSlugs(names)
+		if len(slugs) == 0 {
+			return db
+		}
+		noteIDs := db.Session(&gorm.Session{NewDB: true}).
+			Table("note_tags").
+			Select("note_tags.note_id").
+			Joins("JOIN tags ON tags.id = note_tags.tag_id").
+			Where("tags.slug IN ?", slugs)
+		if all {
+			noteIDs = noteIDs.Group("note_tags.note_id").
+				Having("COUNT(DISTINCT note_tags.tag_id) = ?", len(slugs))
+		}
+		return db.Where(clause.Expr{
+			SQL:  "? IN (?)",
+			Vars: []interface{}{clause.Column{Table: clause.CurrentTable, Name: "id"}, noteIDs},
+		})
+	}
+}
+
+// CountedTags scopes a query on tags to those carried by the notes of the
+// given query, selecting their number as TagCount.Count, most used first
+func CountedTags(notes *gorm.DB) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Select("tags.*, COUNT(note_tags.note_id) AS note_count").
+			Joins("JOIN note_tags ON note_tags.tag_id = tags.id").
+			Where("note_tags.note_id IN (?)", notes.Select("id")).
+			Group("tags.id").
+			Order("note_count DESC, tags.slug")
+	}
+}
@@ -28,15 +28,33 @@ type User struct {
 	// Timestamps for user management
 	LastLogin  *time.Time
 	DateJoined time.Time `gorm:"autoCreateTime"`
+
+	Notes []Note `gorm:"foreignKey:AuthorID" json:",omitempty"`
+
+	// storedPassword is the hash Password held when the user was loaded or
+	// last saved, to tell a new password from the unchanged hash
+	storedPassword string
+}
+
+// AfterFind remembers the stored password hash
+func (u *User) AfterFind(tx *gorm.DB) error {
+	u.storedPassword = u.Password
+	return nil
 }
 
-// BeforeCreate hook to hash password before saving
-func (u *User) BeforeCreate(tx *gorm.DB) error {
+// BeforeSave hashes Password when it was changed, so saving a loaded user,
+// e.g. from the admin, does not hash the hash again. Any new value is hashed,
+// even one that looks like a bcrypt hash.
+func (u *User) BeforeSave(tx *gorm.DB) error {
+	if u.Password == "" || u.Password == u.storedPassword {
+		return nil
+	}
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
 	u.Password = string(hashedPassword)
+	u.storedPassword = u.Password
 	return nil
 }
 
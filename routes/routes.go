@@ -9,10 +9,22 @@ import (
 )
 
 // SetupRoutes configures all application routes
-func SetupRoutes(app *fiber.App, authHandler *handlers.AuthHandler, adminHandler *handlers.AdminHandler, viewHandler *views.ViewHandler, jwtSecret []byte) {
+func SetupRoutes(app *fiber.App, authHandler *handlers.AuthHandler, adminHandler *handlers.AdminHandler, noteHandler *handlers.NoteHandler, viewHandler *views.ViewHandler, healthHandler *handlers.HealthHandler, jwtSecret []byte) {
+	// Health checks
+	setupHealthRoutes(app, healthHandler)
+
 	// Public routes
 	setupPublicRoutes(app, authHandler, viewHandler)
 
+	// Admin routes use their own cookie session; they must be registered
+	// before the catch-all protected group below
+	admin := app.Group("/admin")
+	setupAdminRoutes(admin, authHandler, viewHandler, jwtSecret)
+
+	// Share links are read without an account, so they go before the
+	// protected groups
+	app.Get("/api/shared/:token", noteHandler.GetSharedNote)
+
 	// Protected routes
 	protected := app.Group("/")
 	protected.Use(middleware.Protected(jwtSecret))
@@ -21,17 +33,21 @@ func SetupRoutes(app *fiber.App, authHandler *handlers.AuthHandler, adminHandler
 	// Protected API routes
 	api := app.Group("/api")
 	api.Use(middleware.Protected(jwtSecret))
-	setupAPIRoutes(api, authHandler)
+	setupAPIRoutes(api, authHandler, noteHandler)
 
-	// Admin routes
-	admin := app.Group("/admin")
-	setupAdminRoutes(admin, authHandler, adminHandler)
-
-	// Protected admin API routes
+	// Admin API routes, for staff only like the server-rendered admin
 	adminAPI := api.Group("/admin")
+	adminAPI.Use(middleware.StaffRequired())
 	setupAdminAPIRoutes(adminAPI, adminHandler)
 }
 
+// setupHealthRoutes configures liveness and readiness probes
+func setupHealthRoutes(app fiber.Router, healthHandler *handlers.HealthHandler) {
+	app.Get("/health", healthHandler.Ready)
+	app.Get("/health/live", healthHandler.Live)
+	app.Get("/health/ready", healthHandler.Ready)
+}
+
 // setupPublicRoutes configures public routes
 func setupPublicRoutes(app fiber.Router, authHandler *handlers.AuthHandler, viewHandler *views.ViewHandler) {
 	// Static pages
@@ -39,13 +55,6 @@ func setupPublicRoutes(app fiber.Router, authHandler *handlers.AuthHandler, view
 		return c.Redirect("/login")
 	})
 
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"status": "ok",
-			"db":     true,
-		})
-	})
-
 	// Authentication routes
 	app.Get("/login", viewHandler.LoginPage)
 	app.Post("/validate-username", viewHandler.ValidateUsername)
@@ -59,31 +68,74 @@ func setupProtectedRoutes(router fiber.Router, viewHandler *views.ViewHandler) {
 	router.Get("/dashboard", viewHandler.Dashboard)
 	router.Get("/users/list", viewHandler.UsersList)
 	router.Get("/notes/list", viewHandler.NotesList)
+	router.Post("/notes/preview", viewHandler.MarkdownPreview)
 }
 
 // setupAPIRoutes configures protected API routes
-func setupAPIRoutes(api fiber.Router, authHandler *handlers.AuthHandler) {
+func setupAPIRoutes(api fiber.Router, authHandler *handlers.AuthHandler, noteHandler *handlers.NoteHandler) {
 	api.Get("/auth/validate", authHandler.ValidateToken)
+
+	// Notes
+	api.Get("/notes", noteHandler.ListNotes)
+	api.Post("/notes", noteHandler.CreateNote)
+	api.Get("/notes/search", noteHandler.SearchNotes)
+	api.Get("/notes/:id", noteHandler.GetNote)
+	api.Put("/notes/:id", noteHandler.UpdateNote)
+	api.Patch("/notes/:id", noteHandler.UpdateNote)
+	api.Delete("/notes/:id", noteHandler.DeleteNote)
+	api.Get("/notes/:id/revisions", noteHandler.ListRevisions)
+	api.Get("/notes/:id/revisions/diff", noteHandler.DiffRevisions)
+	api.Get("/notes/:id/revisions/:number", noteHandler.GetRevision)
+	api.Post("/notes/:id/revisions/:number/restore", noteHandler.RestoreRevision)
+	api.Get("/notes/:id/shares", noteHandler.ListShares)
+	api.Post("/notes/:id/shares", noteHandler.ShareNote)
+	api.Delete("/notes/:id/shares/:share", noteHandler.UnshareNote)
+	api.Get("/notes/:id/links", noteHandler.ListShareLinks)
+	api.Post("/notes/:id/links", noteHandler.CreateShareLink)
+	api.Delete("/notes/:id/links/:link", noteHandler.DeleteShareLink)
+	api.Get("/tags", noteHandler.ListTags)
 }
 
-// setupAdminRoutes configures admin panel routes
-func setupAdminRoutes(admin fiber.Router, authHandler *handlers.AuthHandler, adminHandler *handlers.AdminHandler) {
-	admin.Get("/login", func(c *fiber.Ctx) error {
-		return c.Render("admin/login", fiber.Map{
-			"title": "Admin Login",
-		})
-	})
+// setupAdminRoutes configures the admin login and the server-rendered admin pages
+func setupAdminRoutes(admin fiber.Router, authHandler *handlers.AuthHandler, viewHandler *views.ViewHandler, jwtSecret []byte) {
+	admin.Get("/login", viewHandler.AdminLoginPage)
 	admin.Post("/login", authHandler.Login)
+	admin.Get("/logout", authHandler.Logout)
+
+	// Everything below requires a staff session
+	admin.Use(middleware.AdminRequired(jwtSecret))
+	admin.Get("/", viewHandler.AdminIndex)
+	admin.Post("/markdown/preview", viewHandler.MarkdownPreview)
+	admin.Get("/:model", viewHandler.AdminChangeList)
+	admin.Post("/:model/actions", viewHandler.AdminRunAction)
+	admin.Get("/:model/add", viewHandler.AdminAddForm)
+	admin.Post("/:model/add", viewHandler.AdminAdd)
+	admin.Get("/:model/autocomplete", viewHandler.AdminAutocomplete)
+	admin.Get("/:model/:id", viewHandler.AdminChangeForm)
+	admin.Post("/:model/:id", viewHandler.AdminChange)
+	admin.Get("/:model/:id/delete", viewHandler.AdminDeleteConfirm)
+	admin.Post("/:model/:id/delete", viewHandler.AdminDelete)
 }
 
-// setupAdminAPIRoutes configures protected admin API routes
+// setupAdminAPIRoutes configures the staff-only admin API routes
 func setupAdminAPIRoutes(admin fiber.Router, adminHandler *handlers.AdminHandler) {
+	admin.Get("/databases", adminHandler.ListDatabases)
 	admin.Get("/models", adminHandler.ListModels)
+	admin.Get("/models/:model/schema", adminHandler.ModelSchema)
+	admin.Get("/models/:model/actions", adminHandler.ListModelActions)
+	admin.Post("/models/:model/actions/:action", adminHandler.RunModelAction)
 	admin.Get("/models/:model", adminHandler.ListModelEntries)
+	admin.Get("/models/:model/export", adminHandler.ExportModelEntries)
+	admin.Get("/models/:model/autocomplete", adminHandler.AutocompleteModelEntries)
+	admin.Post("/models/:model/import", adminHandler.ImportModelEntries)
 	admin.Get("/models/:model/:id", adminHandler.GetModelEntry)
+	admin.Get("/models/:model/:id/history", adminHandler.ModelEntryHistory)
 	admin.Post("/models/:model", adminHandler.CreateModelEntry)
 	admin.Put("/models/:model/:id", adminHandler.UpdateModelEntry)
+	admin.Patch("/models/:model/:id", adminHandler.PatchModelEntry)
 	admin.Delete("/models/:model/:id", adminHandler.DeleteModelEntry)
+	admin.Post("/models/:model/:id/restore", adminHandler.RestoreModelEntry)
+	admin.Delete("/models/:model/:id/purge", middleware.SuperuserRequired(), adminHandler.PurgeModelEntry)
 }
 
 // NewRoutes initializes all routes
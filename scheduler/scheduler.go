@@ -0,0 +1,110 @@
+// scheduler/scheduler.go
+
+// Package scheduler runs background jobs at a fixed interval inside the
+// server process. Every process runs them, so jobs must tolerate running
+// concurrently with themselves elsewhere.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job does one round of background work as of now. It should give up when
+// ctx is done.
+type Job func(ctx context.Context, now time.Time) error
+
+type namedJob struct {
+	name string
+	fn   Job
+}
+
+// Scheduler runs its jobs one after the other every interval
+type Scheduler struct {
+	interval time.Duration
+	errorLog *log.Logger
+
+	mu     sync.Mutex
+	jobs   []namedJob
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates a Scheduler running jobs every interval and logging their
+// failures to errorLog. A zero interval disables it.
+func New(interval time.Duration, errorLog *log.Logger) *Scheduler {
+	return &Scheduler{interval: interval, errorLog: errorLog}
+}
+
+// Add registers a job; jobs run in the order they were added
+func (s *Scheduler) Add(name string, job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, namedJob{name: name, fn: job})
+}
+
+// Start runs the jobs right away, then every interval until Stop
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.interval <= 0 || s.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel, s.done = cancel, make(chan struct{})
+	go s.loop(ctx, s.done)
+}
+
+// Stop waits for the running round to finish, or for ctx to be done, and
+// stops the scheduler. Its signature fits shutdown.Hook.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.run(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run runs every job once; a failing job does not prevent the others
+func (s *Scheduler) run(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	jobs := append([]namedJob(nil), s.jobs...)
+	s.mu.Unlock()
+
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := job.fn(ctx, now); err != nil && ctx.Err() == nil {
+			s.errorLog.Printf("Scheduled job %q failed: %v", job.name, err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// search/like.go
+package search
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Like searches notes with LIKE, needing no index. It scans the whole table,
+// so it is only the fallback for databases without full-text search.
+type Like struct{}
+
+func (Like) Name() string {
+	return "like"
+}
+
+func (Like) Migrate(db *gorm.DB) error {
+	return nil
+}
+
+func (Like) Index(db *gorm.DB, id uint, title, content string) error {
+	return nil
+}
+
+func (Like) Remove(db *gorm.DB, id uint) error {
+	return nil
+}
+
+func (Like) Rebuild(db *gorm.DB) (int64, error) {
+	return 0, nil
+}
+
+// Match requires every term of q in the title or the content
+func (Like) Match(q string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		terms := Terms(q)
+		if len(terms) == 0 {
+			return db.Where("1 = 0")
+		}
+		for _, term := range terms {
+			// Terms are letters and digits only, so hold no wildcards
+			db = db.Where(clause.Or(
+				clause.Expr{SQL: "LOWER(notes.title) LIKE ?", Vars: []interface{}{"%" + term + "%"}},
+				clause.Expr{SQL: "LOWER(notes.content) LIKE ?", Vars: []interface{}{"%" + term + "%"}},
+			))
+		}
+		return db
+	}
+}
+
+// Rank counts the terms found in the title, then prefers recent notes
+func (Like) Rank(q string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		terms := Terms(q)
+		rank := make([]string, len(terms))
+		vars := make([]interface{}, len(terms))
+		for i, term := range terms {
+			rank[i] = "CASE WHEN LOWER(notes.title) LIKE ? THEN 1 ELSE 0 END"
+			vars[i] = "%" + term + "%"
+		}
+		if len(rank) == 0 {
+			rank = []string{"0"}
+		}
+		return db.Select("notes.id, ("+strings.Join(rank, " + ")+") AS search_rank, notes.content AS search_snippet", vars...).
+			Order("search_rank DESC").
+			Order("notes.updated_at DESC")
+	}
+}
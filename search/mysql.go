@@ -0,0 +1,73 @@
+// search/mysql.go
+package search
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// MySQL searches notes through a FULLTEXT index on their title and content,
+// which MySQL keeps up to date itself. It cannot highlight, so snippets are
+// cut from the content by Highlight.
+type MySQL struct{}
+
+func (MySQL) Name() string {
+	return "mysql-fulltext"
+}
+
+func (MySQL) Migrate(db *gorm.DB) error {
+	if db.Migrator().HasIndex("notes", "idx_notes_search") {
+		return nil
+	}
+	return db.Exec("CREATE FULLTEXT INDEX idx_notes_search ON notes (title, content)").Error
+}
+
+func (MySQL) Index(db *gorm.DB, id uint, title, content string) error {
+	return nil
+}
+
+func (MySQL) Remove(db *gorm.DB, id uint) error {
+	return nil
+}
+
+// Rebuild recreates the FULLTEXT index
+func (b MySQL) Rebuild(db *gorm.DB) (int64, error) {
+	if db.Migrator().HasIndex("notes", "idx_notes_search") {
+		if err := db.Exec("DROP INDEX idx_notes_search ON notes").Error; err != nil {
+			return 0, err
+		}
+	}
+	if err := b.Migrate(db); err != nil {
+		return 0, err
+	}
+	var indexed int64
+	err := db.Table("notes").Where("deleted_at IS NULL").Count(&indexed).Error
+	return indexed, err
+}
+
+func (MySQL) Match(q string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("MATCH (notes.title, notes.content) AGAINST (? IN BOOLEAN MODE)", booleanQuery(q))
+	}
+}
+
+func (MySQL) Rank(q string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Select("notes.id, MATCH (notes.title, notes.content) AGAINST (? IN BOOLEAN MODE) AS search_rank, "+
+			"notes.content AS search_snippet", booleanQuery(q)).
+			Order("search_rank DESC")
+	}
+}
+
+// booleanQuery requires every term of q, the last one as a prefix
+func booleanQuery(q string) string {
+	terms := Terms(q)
+	for i, term := range terms {
+		terms[i] = "+" + term
+	}
+	if len(terms) > 0 {
+		terms[len(terms)-1] += "*"
+	}
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,70 @@
+// search/postgres.go
+package search
+
+import (
+	"gorm.io/gorm"
+)
+
+// Postgres indexes notes in a weighted tsvector column, notes.search_vector,
+// with a GIN index. The "simple" configuration does not stem, so it suits
+// notes in any language.
+type Postgres struct{}
+
+const postgresVector = "setweight(to_tsvector('simple', COALESCE(title, '')), 'A') || " +
+	"setweight(to_tsvector('simple', COALESCE(content, '')), 'B')"
+
+// ts_headline options producing the markers Highlight expects
+const postgresHeadline = "StartSel=" + markStart + ", StopSel=" + markEnd +
+	", MaxFragments=2, MaxWords=20, MinWords=8, FragmentDelimiter=\" … \""
+
+func (Postgres) Name() string {
+	return "postgres-tsvector"
+}
+
+func (b Postgres) Migrate(db *gorm.DB) error {
+	if db.Migrator().HasColumn("notes", "search_vector") {
+		return db.Exec("CREATE INDEX IF NOT EXISTS idx_notes_search ON notes USING GIN (search_vector)").Error
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("ALTER TABLE notes ADD COLUMN search_vector tsvector").Error; err != nil {
+			return err
+		}
+		if _, err := b.Rebuild(tx); err != nil {
+			return err
+		}
+		return tx.Exec("CREATE INDEX IF NOT EXISTS idx_notes_search ON notes USING GIN (search_vector)").Error
+	})
+}
+
+func (Postgres) Index(db *gorm.DB, id uint, title, content string) error {
+	return db.Exec("UPDATE notes SET search_vector = "+
+		"setweight(to_tsvector('simple', CAST(? AS text)), 'A') || "+
+		"setweight(to_tsvector('simple', CAST(? AS text)), 'B') WHERE id = ?", title, content, id).Error
+}
+
+// Remove does nothing: the vector goes with the row, and soft-deleted notes
+// are left out of searches by GORM
+func (Postgres) Remove(db *gorm.DB, id uint) error {
+	return nil
+}
+
+func (Postgres) Rebuild(db *gorm.DB) (int64, error) {
+	result := db.Exec("UPDATE notes SET search_vector = " + postgresVector)
+	return result.RowsAffected, result.Error
+}
+
+func (Postgres) Match(q string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("notes.search_vector @@ websearch_to_tsquery('simple', ?)", q)
+	}
+}
+
+func (Postgres) Rank(q string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Select("notes.id, "+
+			"ts_rank(notes.search_vector, websearch_to_tsquery('simple', ?)) AS search_rank, "+
+			"ts_headline('simple', COALESCE(notes.content, ''), websearch_to_tsquery('simple', ?), ?) AS search_snippet",
+			q, q, postgresHeadline).
+			Order("search_rank DESC")
+	}
+}
@@ -0,0 +1,174 @@
+// search/search.go
+
+// Package search indexes notes for full-text search, using SQLite FTS5,
+// Postgres tsvector or MySQL FULLTEXT depending on the database, and LIKE
+// where none is available. SQLite needs the binary built with
+// -tags sqlite_fts5 for FTS5.
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// Markers the backends put around matched terms in snippets; Highlight turns
+// them into <mark> tags
+const (
+	markStart = "\ue000"
+	markEnd   = "\ue001"
+)
+
+// snippetRunes is the length of the snippets cut from note content in Go
+const snippetRunes = 160
+
+// Backend keeps a full-text index of the notes table and queries it
+type Backend interface {
+	// Name identifies the backend, e.g. "sqlite-fts5"
+	Name() string
+	// Migrate creates the index structures if missing
+	Migrate(db *gorm.DB) error
+	// Index adds or replaces a note in the index
+	Index(db *gorm.DB, id uint, title, content string) error
+	// Remove drops a note from the index
+	Remove(db *gorm.DB, id uint) error
+	// Rebuild indexes every note anew and returns how many were indexed
+	Rebuild(db *gorm.DB) (int64, error)
+	// Match scopes a query on notes to those matching q
+	Match(q string) func(*gorm.DB) *gorm.DB
+	// Rank selects the Hit columns of a query scoped by Match, best first
+	Rank(q string) func(*gorm.DB) *gorm.DB
+}
+
+// Hit is a note matching a search
+type Hit struct {
+	ID      uint    `gorm:"column:id"`
+	Rank    float64 `gorm:"column:search_rank"`    // higher is better
+	Snippet string  `gorm:"column:search_snippet"` // raw, see Highlight
+}
+
+var current Backend = Like{}
+
+// Setup picks the backend for the database, creates its index structures and
+// makes it the one used by Current and the index functions. When that fails,
+// searches fall back to LIKE and the error is returned.
+func Setup(db *gorm.DB) (Backend, error) {
+	var backend Backend
+	switch db.Dialector.Name() {
+	case "sqlite":
+		backend = SQLite{}
+	case "postgres":
+		backend = Postgres{}
+	case "mysql":
+		backend = MySQL{}
+	default:
+		backend = Like{}
+	}
+
+	if err := backend.Migrate(db); err != nil {
+		current = Like{}
+		return current, err
+	}
+	current = backend
+	return backend, nil
+}
+
+// Current returns the backend chosen by Setup, LIKE before it ran
+func Current() Backend {
+	return current
+}
+
+// Index adds or replaces a note in the current index
+func Index(db *gorm.DB, id uint, title, content string) error {
+	return current.Index(db, id, title, content)
+}
+
+// Remove drops a note from the current index
+func Remove(db *gorm.DB, id uint) error {
+	return current.Remove(db, id)
+}
+
+// Terms splits a query into lowercase words, ignoring punctuation
+func Terms(q string) []string {
+	return strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Highlight turns a raw snippet into HTML: the text is escaped and matched
+// terms wrapped in <mark>. Snippets without markers, from backends that
+// cannot highlight, are cut around the first term of q and marked here.
+func Highlight(snippet, q string) string {
+	if !strings.Contains(snippet, markStart) {
+		snippet = markTerms(excerpt(snippet, Terms(q)), Terms(q))
+	}
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, markStart, "<mark>")
+	return strings.ReplaceAll(escaped, markEnd, "</mark>")
+}
+
+// excerpt cuts about snippetRunes runes of text around the first term found
+func excerpt(text string, terms []string) string {
+	if utf8.RuneCountInString(text) <= snippetRunes {
+		return text
+	}
+	lower := strings.ToLower(text)
+	start := -1
+	for _, term := range terms {
+		if i := strings.Index(lower, term); i >= 0 && (start < 0 || i < start) {
+			start = i
+		}
+	}
+
+	runes := []rune(text)
+	from := 0
+	if start > 0 {
+		from = utf8.RuneCountInString(lower[:start]) - snippetRunes/4
+	}
+	if from < 0 {
+		from = 0
+	}
+	to := from + snippetRunes
+	if to > len(runes) {
+		to = len(runes)
+		from = to - snippetRunes
+	}
+
+	cut := string(runes[from:to])
+	if from > 0 {
+		cut = "…" + cut
+	}
+	if to < len(runes) {
+		cut += "…"
+	}
+	return cut
+}
+
+// markTerms wraps the occurrences of terms in text with the markers
+func markTerms(text string, terms []string) string {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// Lowercasing changed byte offsets; leave the text unmarked
+		return text
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		matched := ""
+		for _, term := range terms {
+			if strings.HasPrefix(lower[i:], term) && len(term) > len(matched) {
+				matched = term
+			}
+		}
+		if matched == "" {
+			b.WriteByte(text[i])
+			i++
+			continue
+		}
+		b.WriteString(markStart + text[i:i+len(matched)] + markEnd)
+		i += len(matched)
+	}
+	return b.String()
+}
@@ -0,0 +1,83 @@
+// search/sqlite.go
+package search
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SQLite indexes notes in an FTS5 table, notes_fts, whose rowid is the note ID
+type SQLite struct{}
+
+func (SQLite) Name() string {
+	return "sqlite-fts5"
+}
+
+func (b SQLite) Migrate(db *gorm.DB) error {
+	if db.Migrator().HasTable("notes_fts") {
+		// Fails when the binary was built without FTS5
+		return db.Exec("SELECT rowid FROM notes_fts LIMIT 1").Error
+	}
+	err := db.Exec("CREATE VIRTUAL TABLE notes_fts USING fts5(title, content, tokenize = 'unicode61 remove_diacritics 2')").Error
+	if err != nil {
+		return err
+	}
+	_, err = b.Rebuild(db)
+	return err
+}
+
+func (b SQLite) Index(db *gorm.DB, id uint, title, content string) error {
+	if err := b.Remove(db, id); err != nil {
+		return err
+	}
+	return db.Exec("INSERT INTO notes_fts (rowid, title, content) VALUES (?, ?, ?)", id, title, content).Error
+}
+
+func (SQLite) Remove(db *gorm.DB, id uint) error {
+	return db.Exec("DELETE FROM notes_fts WHERE rowid = ?", id).Error
+}
+
+func (SQLite) Rebuild(db *gorm.DB) (int64, error) {
+	var indexed int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM notes_fts").Error; err != nil {
+			return err
+		}
+		result := tx.Exec("INSERT INTO notes_fts (rowid, title, content) " +
+			"SELECT id, title, COALESCE(content, '') FROM notes WHERE deleted_at IS NULL")
+		indexed = result.RowsAffected
+		return result.Error
+	})
+	return indexed, err
+}
+
+func (SQLite) Match(q string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Joins("JOIN notes_fts ON notes_fts.rowid = notes.id").
+			Where("notes_fts MATCH ?", ftsQuery(q))
+	}
+}
+
+func (SQLite) Rank(q string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		// bm25 is lower for better matches; titles weigh twice the content
+		return db.Select("notes.id, -bm25(notes_fts, 2.0, 1.0) AS search_rank, "+
+			"snippet(notes_fts, -1, ?, ?, '…', 16) AS search_snippet", markStart, markEnd).
+			Order("search_rank DESC")
+	}
+}
+
+// ftsQuery requires every term of q, the last one as a prefix so results
+// follow typing. Terms are quoted, so FTS5 operators in q are plain words.
+func ftsQuery(q string) string {
+	terms := Terms(q)
+	if len(terms) == 0 {
+		return `""`
+	}
+	for i, term := range terms {
+		terms[i] = `"` + term + `"`
+	}
+	terms[len(terms)-1] += "*"
+	return strings.Join(terms, " ")
+}
@@ -1,8 +1,13 @@
 package settings
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/mviner000/eyygo/config"
 
@@ -10,45 +15,253 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
+// NewDBConnection connects to the primary database and routes reads to its replicas, if any
 func NewDBConnection(config *config.Config) (*gorm.DB, error) {
-	var dialector gorm.Dialector
+	return openDatabase(config.Database, &gorm.Config{})
+}
+
+// openDatabase connects with retries, registers read replicas and tunes the pool.
+// Timestamps GORM sets (CreatedAt, UpdatedAt, DeletedAt) are in UTC, so that
+// SQLite, which compares times as text, orders them right.
+func openDatabase(cfg config.DatabaseConfig, gormConfig *gorm.Config) (*gorm.DB, error) {
+	if gormConfig.NowFunc == nil {
+		gormConfig.NowFunc = func() time.Time {
+			return time.Now().UTC()
+		}
+	}
+
+	driver, dialector, err := newDialector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Retry with exponential backoff so the app survives a database that starts after it
+	var db *gorm.DB
+	backoff := cfg.ConnectBackoff
+	for attempt := 0; ; attempt++ {
+		db, err = gorm.Open(dialector, gormConfig)
+		if err == nil {
+			break
+		}
+		closeQuietly(db)
+
+		if attempt >= cfg.ConnectRetries {
+			return nil, fmt.Errorf("failed to connect to database after %d attempt(s): %v", attempt+1, err)
+		}
+		log.Printf("Failed to connect to %s database (attempt %d/%d): %v; retrying in %s",
+			driver, attempt+1, cfg.ConnectRetries+1, err, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	if len(cfg.Replicas) > 0 {
+		if err := useReplicas(db, cfg); err != nil {
+			closeQuietly(db)
+			return nil, err
+		}
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database instance: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
-	switch config.DBDriver {
+	log.Printf("Connected to %s database", driver)
+	return db, nil
+}
+
+// useReplicas splits reads and writes: queries go to a random replica,
+// while writes, raw execs and transactions stay on the primary
+func useReplicas(db *gorm.DB, cfg config.DatabaseConfig) error {
+	replicas := make([]gorm.Dialector, 0, len(cfg.Replicas))
+	for _, replicaURL := range cfg.Replicas {
+		replicaCfg := cfg
+		replicaCfg.URL = replicaURL
+		_, dialector, err := dialectorFromURL(replicaCfg)
+		if err != nil {
+			return fmt.Errorf("invalid replica: %v", err)
+		}
+		replicas = append(replicas, dialector)
+	}
+
+	resolver := dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   dbresolver.RandomPolicy{},
+	}).
+		SetMaxOpenConns(cfg.MaxOpenConns).
+		SetMaxIdleConns(cfg.MaxIdleConns).
+		SetConnMaxLifetime(cfg.ConnMaxLifetime).
+		SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if err := db.Use(resolver); err != nil {
+		return fmt.Errorf("failed to connect to replicas: %v", err)
+	}
+	log.Printf("Routing reads to %d replica(s)", len(replicas))
+	return nil
+}
+
+// newDialector builds the GORM dialector from DATABASE_URL when set, otherwise from the individual fields
+func newDialector(cfg config.DatabaseConfig) (string, gorm.Dialector, error) {
+	if cfg.URL != "" {
+		return dialectorFromURL(cfg)
+	}
+
+	switch cfg.Driver {
 	case "sqlite":
-		dialector = sqlite.Open(fmt.Sprintf("%s.db", config.DBName))
+		return cfg.Driver, sqlite.Open(sqliteDSN(fmt.Sprintf("%s.db", cfg.Name), url.Values{}, cfg)), nil
 
 	case "mysql":
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-			config.DBUser,
-			config.DBPassword,
-			config.DBHost,
-			config.DBPort,
-			config.DBName,
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?%s",
+			cfg.User,
+			cfg.Password,
+			cfg.Host,
+			cfg.Port,
+			cfg.Name,
+			mysqlParams(url.Values{}, cfg).Encode(),
 		)
-		dialector = mysql.Open(dsn)
+		return cfg.Driver, mysql.Open(dsn), nil
 
 	case "postgresql":
 		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
-			config.DBHost,
-			config.DBUser,
-			config.DBPassword,
-			config.DBName,
-			config.DBPort,
-			config.DBSSLMode,
+			cfg.Host,
+			cfg.User,
+			cfg.Password,
+			cfg.Name,
+			cfg.Port,
+			cfg.SSLMode,
 		)
-		dialector = postgres.Open(dsn)
+		if cfg.StatementTimeout > 0 {
+			dsn += fmt.Sprintf(" statement_timeout=%d", cfg.StatementTimeout.Milliseconds())
+		}
+		return cfg.Driver, postgres.Open(dsn), nil
 
 	default:
-		return nil, fmt.Errorf("unsupported database driver: %s", config.DBDriver)
+		return "", nil, fmt.Errorf("unsupported database driver: %s", cfg.Driver)
 	}
+}
 
-	db, err := gorm.Open(dialector, &gorm.Config{})
+// dialectorFromURL supports postgres://, postgresql://, mysql:// and sqlite:// URLs.
+// Query parameters are passed through to the driver.
+func dialectorFromURL(cfg config.DatabaseConfig) (string, gorm.Dialector, error) {
+	u, err := url.Parse(cfg.URL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %v", err)
+		return "", nil, fmt.Errorf("invalid DATABASE_URL: %v", err)
 	}
+	query := u.Query()
 
-	log.Printf("Connected to %s database", config.DBDriver)
-	return db, nil
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		if cfg.StatementTimeout > 0 && query.Get("statement_timeout") == "" {
+			query.Set("statement_timeout", fmt.Sprint(cfg.StatementTimeout.Milliseconds()))
+		}
+		u.RawQuery = query.Encode()
+		return "postgresql", postgres.Open(u.String()), nil
+
+	case "mysql":
+		password, _ := u.User.Password()
+		dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?%s",
+			u.User.Username(),
+			password,
+			u.Host,
+			strings.TrimPrefix(u.Path, "/"),
+			mysqlParams(query, cfg).Encode(),
+		)
+		return "mysql", mysql.Open(dsn), nil
+
+	case "sqlite", "sqlite3":
+		// sqlite:///abs/path.db and sqlite://relative.db
+		path := u.Host + u.Path
+		if path == "" {
+			return "", nil, fmt.Errorf("invalid DATABASE_URL: missing sqlite file path")
+		}
+		return "sqlite", sqlite.Open(sqliteDSN(path, query, cfg)), nil
+
+	default:
+		return "", nil, fmt.Errorf("unsupported DATABASE_URL scheme: %s", u.Scheme)
+	}
+}
+
+// mysqlParams adds the defaults this app relies on unless the caller already set them
+func mysqlParams(params url.Values, cfg config.DatabaseConfig) url.Values {
+	setDefault(params, "charset", "utf8mb4")
+	setDefault(params, "parseTime", "True")
+	setDefault(params, "loc", "Local")
+	if cfg.StatementTimeout > 0 {
+		// Sent as a session variable; limits SELECT statements on MySQL 5.7.8+
+		setDefault(params, "max_execution_time", fmt.Sprint(cfg.StatementTimeout.Milliseconds()))
+	}
+	return params
+}
+
+// sqliteDSN appends the configured pragmas to the file path
+func sqliteDSN(path string, params url.Values, cfg config.DatabaseConfig) string {
+	foreignKeys := "off"
+	if cfg.SQLiteForeignKeys {
+		foreignKeys = "on"
+	}
+	setDefault(params, "_journal_mode", strings.ToUpper(cfg.SQLiteJournalMode))
+	setDefault(params, "_busy_timeout", fmt.Sprint(cfg.SQLiteBusyTimeout.Milliseconds()))
+	setDefault(params, "_foreign_keys", foreignKeys)
+	return path + "?" + params.Encode()
+}
+
+func setDefault(params url.Values, key, value string) {
+	if params.Get(key) == "" {
+		params.Set(key, value)
+	}
+}
+
+// connPools returns the connection pools of db: the primary's, then those of
+// its read replicas
+func connPools(db *gorm.DB) []*sql.DB {
+	var pools []*sql.DB
+	add := func(pool gorm.ConnPool) error {
+		sqlDB, ok := pool.(*sql.DB)
+		if !ok {
+			return nil
+		}
+		for _, seen := range pools {
+			if seen == sqlDB {
+				return nil
+			}
+		}
+		pools = append(pools, sqlDB)
+		return nil
+	}
+
+	if sqlDB, err := db.DB(); err == nil {
+		add(sqlDB)
+	}
+	for _, plugin := range db.Config.Plugins {
+		if resolver, ok := plugin.(*dbresolver.DBResolver); ok {
+			resolver.Call(add)
+		}
+	}
+	return pools
+}
+
+// closeDatabase closes the connection pools of db and its read replicas
+func closeDatabase(db *gorm.DB) error {
+	var errs []error
+	for _, pool := range connPools(db) {
+		if err := pool.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// closeQuietly releases the pools of a failed connection attempt
+func closeQuietly(db *gorm.DB) {
+	if db == nil {
+		return
+	}
+	closeDatabase(db)
 }
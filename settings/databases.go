@@ -0,0 +1,82 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/mviner000/eyygo/config"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// Databases holds every configured connection by name. The primary
+// database from the DB_* settings is always available as config.DefaultDatabase.
+type Databases struct {
+	conns map[string]*gorm.DB
+}
+
+// OpenDatabases connects to the primary database (with its replicas) and every
+// database listed in DATABASES. Named databases share the primary's pool settings.
+// A nil gormLogger keeps GORM's default logger.
+func OpenDatabases(cfg *config.Config, gormLogger gormlogger.Interface) (*Databases, error) {
+	named, err := cfg.NamedDatabases()
+	if err != nil {
+		return nil, err
+	}
+
+	dbs := &Databases{conns: make(map[string]*gorm.DB)}
+
+	db, err := openDatabase(cfg.Database, &gorm.Config{Logger: gormLogger})
+	if err != nil {
+		return nil, err
+	}
+	dbs.conns[config.DefaultDatabase] = db
+
+	for name, dsn := range named {
+		dbCfg := cfg.Database
+		dbCfg.URL = dsn
+		dbCfg.Replicas = nil
+
+		db, err := openDatabase(dbCfg, &gorm.Config{Logger: gormLogger})
+		if err != nil {
+			dbs.Close()
+			return nil, fmt.Errorf("database %q: %v", name, err)
+		}
+		dbs.conns[name] = db
+	}
+
+	return dbs, nil
+}
+
+// Default returns the primary database
+func (d *Databases) Default() *gorm.DB {
+	return d.conns[config.DefaultDatabase]
+}
+
+// Using returns the database with the given name
+func (d *Databases) Using(name string) (*gorm.DB, bool) {
+	db, exists := d.conns[name]
+	return db, exists
+}
+
+// Names returns the names of all configured databases, sorted
+func (d *Databases) Names() []string {
+	names := make([]string, 0, len(d.conns))
+	for name := range d.conns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close closes every connection pool, read replicas included
+func (d *Databases) Close() error {
+	var errs []error
+	for name, db := range d.conns {
+		if err := closeDatabase(db); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,72 @@
+// shutdown/shutdown.go
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Hook releases a resource during shutdown. It should give up when ctx is done.
+type Hook func(ctx context.Context) error
+
+type namedHook struct {
+	name string
+	fn   Hook
+}
+
+// Manager runs registered hooks when the application stops
+type Manager struct {
+	mu    sync.Mutex
+	hooks []namedHook
+}
+
+// NewManager creates an empty Manager
+func NewManager() *Manager {
+	return &Manager{}
+}
+
+// Register adds a hook. Hooks run in reverse registration order, so resources
+// opened first (like the database) are closed last.
+func (m *Manager) Register(name string, hook Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, namedHook{name: name, fn: hook})
+}
+
+// Run executes every hook in reverse order and returns the errors of those that failed.
+// A failing hook does not prevent the remaining ones from running. Once ctx is
+// done, Run stops waiting for the running hook, skips the remaining ones and
+// reports them as errors.
+func (m *Manager) Run(ctx context.Context) []error {
+	m.mu.Lock()
+	hooks := append([]namedHook(nil), m.hooks...)
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("%s: skipped: %v", hooks[i].name, ctx.Err()))
+			continue
+		}
+		if err := runHook(ctx, hooks[i].fn); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", hooks[i].name, err))
+		}
+	}
+	return errs
+}
+
+// runHook runs a hook, returning early with ctx's error when ctx is done
+// before the hook returns; a hook ignoring ctx is then left running
+func runHook(ctx context.Context, hook Hook) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- hook(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,606 @@
+// views/admin.go
+package views
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/mviner000/eyygo/admin"
+	"github.com/mviner000/eyygo/middleware"
+	"gorm.io/gorm"
+)
+
+const adminLayout = "admin/layout"
+
+// adminModelLink is a sidebar or index entry
+type adminModelLink struct {
+	Name  string
+	Label string
+	URL   string
+	Count int64
+}
+
+// adminColumn is a changelist header, sortable when SortURL is set
+type adminColumn struct {
+	Label     string
+	SortURL   string
+	SortedAsc bool
+	SortedDsc bool
+}
+
+// adminRow is a changelist row
+type adminRow struct {
+	ID        string
+	ChangeURL string
+	Cells     []string
+}
+
+// adminFilter is a changelist sidebar filter
+type adminFilter struct {
+	Label   string
+	Choices []adminFilterChoice
+}
+
+type adminFilterChoice struct {
+	Label    string
+	URL      string
+	Selected bool
+}
+
+// adminFormField is a change form input with its current value
+type adminFormField struct {
+	admin.Field
+	InputName string
+	Value     string
+	Checked   bool
+	Required  bool
+}
+
+// adminInline is a table of child rows edited on the parent's change form
+type adminInline struct {
+	Name   string
+	Label  string
+	Fields []admin.Field
+	Rows   []adminInlineRow
+}
+
+// adminInlineRow is an existing child, or the empty row for a new one when ID is empty
+type adminInlineRow struct {
+	ID         string
+	ChangeURL  string
+	DeleteName string
+	Fields     []adminFormField
+}
+
+// AdminLoginPage renders the admin login form
+func (h *ViewHandler) AdminLoginPage(c *fiber.Ctx) error {
+	return c.Render("admin/login", fiber.Map{
+		"Title":       "Admin Login",
+		"Next":        c.Query("next", "/admin/"),
+		"CurrentYear": time.Now().Year(),
+	}, "layouts/auth")
+}
+
+// AdminIndex lists every registered model with its row count
+func (h *ViewHandler) AdminIndex(c *fiber.Ctx) error {
+	links := h.adminModelLinks()
+	for i := range links {
+		modelAdmin, _ := admin.Site.GetModelAdmin(links[i].Name)
+		modelAdmin.DB.WithContext(c.UserContext()).Model(modelAdmin.Model).Count(&links[i].Count)
+	}
+
+	return c.Render("admin/index", h.adminContext(c, "Site administration", fiber.Map{
+		"ModelLinks": links,
+	}), adminLayout)
+}
+
+// AdminChangeList renders the searchable, filterable, sortable and paginated list of a model.
+// HTMX requests targeting the results only receive the results fragment.
+func (h *ViewHandler) AdminChangeList(c *fiber.Ctx) error {
+	modelAdmin, err := h.adminModel(c)
+	if err != nil {
+		return err
+	}
+
+	query := c.Queries()
+	params := modelAdmin.ParseListParams(query)
+	db := modelAdmin.DB.WithContext(c.UserContext())
+
+	filtered, err := modelAdmin.ApplyFilters(db.Model(modelAdmin.Model), params)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	var count int64
+	if err := filtered.Session(&gorm.Session{}).Count(&count).Error; err != nil {
+		return err
+	}
+	results := modelAdmin.NewSlice()
+	if err := modelAdmin.Preloaded(filtered).Offset(params.Offset()).Limit(params.PerPage).Find(results).Error; err != nil {
+		return err
+	}
+
+	baseURL := "/admin/" + modelAdmin.Name + "/"
+	listColumns := modelAdmin.ListColumns()
+
+	columns := make([]adminColumn, 0, len(listColumns))
+	for _, field := range listColumns {
+		column := adminColumn{Label: field.Label}
+		if slices.Contains(modelAdmin.OrderFields, field.Name) {
+			ordering := field.DBName
+			column.SortedAsc = params.Ordering == field.DBName
+			column.SortedDsc = params.Ordering == "-"+field.DBName
+			if column.SortedAsc {
+				ordering = "-" + field.DBName
+			}
+			column.SortURL = listURL(baseURL, query, map[string]string{admin.ParamOrdering: ordering, admin.ParamPage: ""})
+		}
+		columns = append(columns, column)
+	}
+
+	entries := reflect.ValueOf(results).Elem()
+	rows := make([]adminRow, 0, entries.Len())
+	for i := 0; i < entries.Len(); i++ {
+		entry := entries.Index(i).Addr().Interface()
+		id := fmt.Sprint(modelAdmin.PrimaryKey(entry))
+		row := adminRow{ID: id, ChangeURL: baseURL + url.PathEscape(id) + "/"}
+		for _, field := range listColumns {
+			row.Cells = append(row.Cells, modelAdmin.Display(entry, field))
+		}
+		rows = append(rows, row)
+	}
+
+	var filters []adminFilter
+	for _, name := range modelAdmin.FilterFields {
+		field, choices, err := modelAdmin.FilterOptions(db, name)
+		if err != nil {
+			continue
+		}
+		filter := adminFilter{Label: field.Label}
+		filter.Choices = append(filter.Choices, adminFilterChoice{
+			Label:    "All",
+			URL:      listURL(baseURL, query, map[string]string{field.DBName: "", admin.ParamPage: ""}),
+			Selected: params.Filters[field.DBName] == "",
+		})
+		for _, choice := range choices {
+			filter.Choices = append(filter.Choices, adminFilterChoice{
+				Label:    choice.Label,
+				URL:      listURL(baseURL, query, map[string]string{field.DBName: choice.Value, admin.ParamPage: ""}),
+				Selected: params.Filters[field.DBName] == choice.Value,
+			})
+		}
+		filters = append(filters, filter)
+	}
+
+	actions := modelAdmin.GetActions()
+
+	totalPages := int((count + int64(params.PerPage) - 1) / int64(params.PerPage))
+	data := h.adminContext(c, admin.Label(modelAdmin.Name)+" list", fiber.Map{
+		"Model":      modelAdmin.Name,
+		"ModelLabel": admin.Label(modelAdmin.Name),
+		"BaseURL":    baseURL,
+		"Search":     params.Search,
+		"Searchable": len(modelAdmin.SearchFields) > 0,
+		"Ordering":   params.Ordering,
+		"Columns":    columns,
+		"Rows":       rows,
+		"Filters":    filters,
+		"Actions":    actions,
+		"Count":      count,
+		"Page":       params.Page,
+		"TotalPages": totalPages,
+	})
+	if params.Page > 1 {
+		data["PrevURL"] = listURL(baseURL, query, map[string]string{admin.ParamPage: strconv.Itoa(params.Page - 1)})
+	}
+	if params.Page < totalPages {
+		data["NextURL"] = listURL(baseURL, query, map[string]string{admin.ParamPage: strconv.Itoa(params.Page + 1)})
+	}
+
+	if c.Get("HX-Target") == "changelist-results" {
+		return c.Render("admin/changelist_results", data)
+	}
+	return c.Render("admin/changelist", data, adminLayout)
+}
+
+// AdminRunAction runs a bulk action on the rows selected in the changelist
+func (h *ViewHandler) AdminRunAction(c *fiber.Ctx) error {
+	modelAdmin, err := h.adminModel(c)
+	if err != nil {
+		return err
+	}
+
+	var raw []interface{}
+	for _, id := range c.Request().PostArgs().PeekMulti("ids") {
+		raw = append(raw, string(id))
+	}
+	ids := admin.ParseIDs(raw)
+	if len(ids) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "No entries selected")
+	}
+
+	action, ok := modelAdmin.GetAction(c.FormValue("action"))
+	if !ok {
+		return fiber.NewError(fiber.StatusNotFound, "Action not found")
+	}
+	result, err := modelAdmin.RunAction(middleware.AuditContext(c), action.Name, ids)
+	if err != nil {
+		return err
+	}
+
+	return c.Render("admin/action_result", h.adminContext(c, action.Label, fiber.Map{
+		"ModelLabel": admin.Label(modelAdmin.Name),
+		"BaseURL":    "/admin/" + modelAdmin.Name + "/",
+		"Result":     result,
+	}), adminLayout)
+}
+
+// AdminAddForm renders an empty form for a new entry
+func (h *ViewHandler) AdminAddForm(c *fiber.Ctx) error {
+	modelAdmin, err := h.adminModel(c)
+	if err != nil {
+		return err
+	}
+	return h.renderAdminForm(c, modelAdmin, modelAdmin.NewEntry(), true, "", fiber.StatusOK)
+}
+
+// AdminAdd creates an entry from the submitted form
+func (h *ViewHandler) AdminAdd(c *fiber.Ctx) error {
+	modelAdmin, err := h.adminModel(c)
+	if err != nil {
+		return err
+	}
+
+	entry := modelAdmin.NewEntry()
+	if err := bindAdminForm(c, modelAdmin, entry, true); err != nil {
+		return h.renderAdminForm(c, modelAdmin, entry, true, err.Error(), fiber.StatusUnprocessableEntity)
+	}
+	if err := modelAdmin.CreateEntry(middleware.AuditContext(c), entry); err != nil {
+		return h.renderAdminForm(c, modelAdmin, entry, true, err.Error(), fiber.StatusUnprocessableEntity)
+	}
+
+	return adminRedirect(c, "/admin/"+modelAdmin.Name+"/")
+}
+
+// AdminChangeForm renders the form of an existing entry
+func (h *ViewHandler) AdminChangeForm(c *fiber.Ctx) error {
+	modelAdmin, entry, err := h.adminEntry(c)
+	if err != nil {
+		return err
+	}
+	return h.renderAdminForm(c, modelAdmin, entry, false, "", fiber.StatusOK)
+}
+
+// AdminChange updates an entry from the submitted form
+func (h *ViewHandler) AdminChange(c *fiber.Ctx) error {
+	modelAdmin, entry, err := h.adminEntry(c)
+	if err != nil {
+		return err
+	}
+
+	before := modelAdmin.Snapshot(entry)
+	if err := bindAdminForm(c, modelAdmin, entry, false); err != nil {
+		return h.renderAdminForm(c, modelAdmin, entry, false, err.Error(), fiber.StatusUnprocessableEntity)
+	}
+	saveInlines := func(tx *gorm.DB) error {
+		for _, inline := range modelAdmin.GetInlines() {
+			if err := inline.Save(tx, entry, inlineRows(c, inline)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var conflict *admin.ConflictError
+	err = modelAdmin.UpdateEntryIfMatch(middleware.AuditContext(c), c.FormValue("etag"), before, entry, saveInlines)
+	if errors.As(err, &conflict) {
+		return h.renderAdminForm(c, modelAdmin, entry, false,
+			"This entry was changed by someone else since you opened it. Reload the page to see their changes.", fiber.StatusConflict)
+	}
+	if err != nil {
+		return h.renderAdminForm(c, modelAdmin, entry, false, err.Error(), fiber.StatusUnprocessableEntity)
+	}
+
+	return adminRedirect(c, "/admin/"+modelAdmin.Name+"/")
+}
+
+// AdminAutocomplete renders the relation choices matching ?q= as <option> elements
+func (h *ViewHandler) AdminAutocomplete(c *fiber.Ctx) error {
+	modelAdmin, err := h.adminModel(c)
+	if err != nil {
+		return err
+	}
+
+	choices, err := modelAdmin.Autocomplete(modelAdmin.DB.WithContext(c.UserContext()), c.Query("q"), admin.DefaultAutocompleteLimit)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return c.Render("admin/autocomplete_options", fiber.Map{
+		"Choices": choices,
+	})
+}
+
+// AdminDeleteConfirm asks for confirmation before deleting an entry
+func (h *ViewHandler) AdminDeleteConfirm(c *fiber.Ctx) error {
+	modelAdmin, entry, err := h.adminEntry(c)
+	if err != nil {
+		return err
+	}
+
+	return c.Render("admin/delete_confirm", h.adminContext(c, "Delete "+admin.Label(modelAdmin.Name), fiber.Map{
+		"Model":      modelAdmin.Name,
+		"ModelLabel": admin.Label(modelAdmin.Name),
+		"ID":         fmt.Sprint(modelAdmin.PrimaryKey(entry)),
+		"Summary":    entrySummary(modelAdmin, entry),
+	}), adminLayout)
+}
+
+// AdminDelete deletes an entry
+func (h *ViewHandler) AdminDelete(c *fiber.Ctx) error {
+	modelAdmin, entry, err := h.adminEntry(c)
+	if err != nil {
+		return err
+	}
+	if err := modelAdmin.DeleteEntry(middleware.AuditContext(c), entry); err != nil {
+		return err
+	}
+	return adminRedirect(c, "/admin/"+modelAdmin.Name+"/")
+}
+
+func (h *ViewHandler) renderAdminForm(c *fiber.Ctx, modelAdmin *admin.ModelAdmin, entry interface{}, adding bool, formError string, status int) error {
+	db := modelAdmin.DB.WithContext(c.UserContext())
+
+	fields := make([]adminFormField, 0, len(modelAdmin.FormFields))
+	for _, field := range modelAdmin.FormColumns() {
+		formField := newFormField(field, field.DBName, admin.FieldValue(entry, field.Name), adding)
+		if field.Input == admin.InputRelation {
+			// Options of a relation input
+			formField.Choices = relationChoices(db, modelAdmin, entry, field)
+		}
+		fields = append(fields, formField)
+	}
+
+	var inlines []adminInline
+	if !adding {
+		for _, inline := range modelAdmin.GetInlines() {
+			form, err := inlineForm(c, db, inline, entry)
+			if err != nil {
+				return err
+			}
+			inlines = append(inlines, form)
+		}
+	}
+
+	title := "Add " + admin.Label(modelAdmin.Name)
+	action := "/admin/" + modelAdmin.Name + "/add/"
+	id := ""
+	// The version the form was opened at, for optimistic locking on save
+	etag := c.FormValue("etag")
+	if !adding {
+		if etag == "" {
+			etag = modelAdmin.ETag(entry)
+		}
+		id = fmt.Sprint(modelAdmin.PrimaryKey(entry))
+		title = "Change " + admin.Label(modelAdmin.Name)
+		action = "/admin/" + modelAdmin.Name + "/" + url.PathEscape(id) + "/"
+	}
+
+	return c.Status(status).Render("admin/change_form", h.adminContext(c, title, fiber.Map{
+		"Model":      modelAdmin.Name,
+		"ModelLabel": admin.Label(modelAdmin.Name),
+		"Adding":     adding,
+		"ID":         id,
+		"Action":     action,
+		"ETag":       etag,
+		"Fields":     fields,
+		"Inlines":    inlines,
+		"Error":      formError,
+	}), adminLayout)
+}
+
+// newFormField describes an input named name showing value
+func newFormField(field admin.Field, name string, value interface{}, adding bool) adminFormField {
+	formField := adminFormField{Field: field, InputName: name, Required: field.Required}
+	switch field.Input {
+	case admin.InputCheckbox:
+		formField.Checked, _ = value.(bool)
+	case admin.InputPassword:
+		// Never echo secrets; on change an empty password keeps the current one
+		formField.Required = adding && field.Required
+	default:
+		formField.Value = admin.FormatValue(value, field.Input)
+	}
+	return formField
+}
+
+// relationChoices offers the current related object followed by the first
+// autocomplete choices; the search box of the input loads others
+func relationChoices(db *gorm.DB, modelAdmin *admin.ModelAdmin, entry interface{}, field admin.Field) []admin.Choice {
+	var choices []admin.Choice
+	current, hasCurrent := modelAdmin.RelatedChoice(db, entry, field)
+	if hasCurrent {
+		choices = append(choices, current)
+	}
+	relatedAdmin, _ := modelAdmin.RelatedModel(field)
+	more, err := relatedAdmin.Autocomplete(db, "", admin.DefaultAutocompleteLimit)
+	if err != nil {
+		return choices
+	}
+	for _, choice := range more {
+		if !hasCurrent || fmt.Sprint(choice.ID) != fmt.Sprint(current.ID) {
+			choices = append(choices, choice)
+		}
+	}
+	return choices
+}
+
+// inlineForm lists the children of parent with an empty row for adding one.
+// After a failed submission the submitted values are shown again.
+func inlineForm(c *fiber.Ctx, db *gorm.DB, inline admin.Inline, parent interface{}) (adminInline, error) {
+	form := adminInline{Name: inline.Name, Label: inline.Label, Fields: inline.Fields}
+	children, err := inline.Children(db, parent)
+	if err != nil {
+		return form, err
+	}
+
+	submitted := c.Method() == fiber.MethodPost
+	row := func(id string, child interface{}) adminInlineRow {
+		r := adminInlineRow{ID: id, DeleteName: inlineInputName(inline, id, "delete")}
+		if id != "" {
+			r.ChangeURL = "/admin/" + inline.Admin.Name + "/" + url.PathEscape(id) + "/"
+		}
+		for _, field := range inline.Fields {
+			name := inlineInputName(inline, id, field.DBName)
+			formField := newFormField(field, name, admin.FieldValue(child, field.Name), id == "")
+			if submitted && field.Input != admin.InputPassword {
+				formField.Value = c.FormValue(name)
+				formField.Checked = c.FormValue(name) != ""
+			}
+			r.Fields = append(r.Fields, formField)
+		}
+		return r
+	}
+
+	for _, child := range children {
+		form.Rows = append(form.Rows, row(fmt.Sprint(inline.Admin.PrimaryKey(child)), child))
+	}
+	form.Rows = append(form.Rows, row("", inline.Admin.NewEntry()))
+	return form, nil
+}
+
+// inlineRows reads the submitted rows of an inline. The form lists the existing
+// children in "<Inline>-ids"; inputs are named "<Inline>-<id>-<column>", or
+// "<Inline>-new-<column>" for the added row.
+func inlineRows(c *fiber.Ctx, inline admin.Inline) []admin.InlineRow {
+	var rows []admin.InlineRow
+	for _, id := range c.Request().PostArgs().PeekMulti(inline.Name + "-ids") {
+		rows = append(rows, inlineRow(c, inline, string(id)))
+	}
+	return append(rows, inlineRow(c, inline, ""))
+}
+
+func inlineRow(c *fiber.Ctx, inline admin.Inline, id string) admin.InlineRow {
+	row := admin.InlineRow{
+		ID:     id,
+		Values: make(map[string]string),
+		Delete: c.FormValue(inlineInputName(inline, id, "delete")) != "",
+	}
+	for _, field := range inline.Fields {
+		value := c.FormValue(inlineInputName(inline, id, field.DBName))
+		if field.Input == admin.InputCheckbox {
+			value = strconv.FormatBool(value != "")
+		}
+		row.Values[field.DBName] = value
+	}
+	return row
+}
+
+func inlineInputName(inline admin.Inline, id, suffix string) string {
+	if id == "" {
+		id = "new"
+	}
+	return inline.Name + "-" + id + "-" + suffix
+}
+
+// bindAdminForm validates the submitted FormFields and assigns them to entry.
+// Unchecked checkboxes are not submitted by browsers, so they are read as false.
+func bindAdminForm(c *fiber.Ctx, modelAdmin *admin.ModelAdmin, entry interface{}, adding bool) error {
+	values := make(map[string]string)
+	for _, field := range modelAdmin.FormColumns() {
+		value := c.FormValue(field.DBName)
+		if field.Input == admin.InputCheckbox {
+			value = strconv.FormatBool(value != "")
+		}
+		values[field.DBName] = value
+	}
+	return modelAdmin.SetValues(c.UserContext(), entry, values, adding)
+}
+
+// adminContext adds the layout data shared by every admin page
+func (h *ViewHandler) adminContext(c *fiber.Ctx, title string, data fiber.Map) fiber.Map {
+	data["Title"] = title
+	data["SidebarModels"] = h.adminModelLinks()
+	if token, ok := c.Locals("user").(*jwt.Token); ok {
+		if claims, ok := token.Claims.(jwt.MapClaims); ok {
+			data["Username"] = claims["username"]
+		}
+	}
+	return data
+}
+
+func (h *ViewHandler) adminModelLinks() []adminModelLink {
+	names := admin.Site.GetModelNames()
+	links := make([]adminModelLink, 0, len(names))
+	for _, name := range names {
+		links = append(links, adminModelLink{Name: name, Label: admin.Label(name), URL: "/admin/" + name + "/"})
+	}
+	return links
+}
+
+func (h *ViewHandler) adminModel(c *fiber.Ctx) (*admin.ModelAdmin, error) {
+	modelAdmin, exists := admin.Site.GetModelAdmin(c.Params("model"))
+	if !exists {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Model not found")
+	}
+	return modelAdmin, nil
+}
+
+func (h *ViewHandler) adminEntry(c *fiber.Ctx) (*admin.ModelAdmin, interface{}, error) {
+	modelAdmin, err := h.adminModel(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entry, err := modelAdmin.FindEntry(modelAdmin.DB.WithContext(c.UserContext()), c.Params("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, fiber.NewError(fiber.StatusNotFound, "Entry not found")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return modelAdmin, entry, nil
+}
+
+// adminRedirect redirects both plain and HTMX-boosted form submissions
+func adminRedirect(c *fiber.Ctx, location string) error {
+	if c.Get("HX-Request") == "true" {
+		c.Set("HX-Location", location)
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+	return c.Redirect(location, fiber.StatusSeeOther)
+}
+
+// entrySummary describes an entry by its list fields for confirmation pages
+func entrySummary(modelAdmin *admin.ModelAdmin, entry interface{}) []string {
+	var summary []string
+	for _, field := range modelAdmin.ListColumns() {
+		summary = append(summary, field.Label+": "+admin.FormatValue(admin.FieldValue(entry, field.Name), ""))
+	}
+	return summary
+}
+
+// listURL rebuilds a changelist URL from the current query with some parameters
+// replaced; empty values remove the parameter
+func listURL(base string, query map[string]string, overrides map[string]string) string {
+	values := url.Values{}
+	for key, value := range query {
+		values.Set(key, value)
+	}
+	for key, value := range overrides {
+		if value == "" {
+			values.Del(key)
+		} else {
+			values.Set(key, value)
+		}
+	}
+	if encoded := values.Encode(); encoded != "" {
+		return base + "?" + encoded
+	}
+	return base
+}
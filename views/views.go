@@ -2,15 +2,22 @@
 package views
 
 import (
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/mviner000/eyygo/markdown"
+	"github.com/mviner000/eyygo/middleware"
 	"github.com/mviner000/eyygo/models"
+	"github.com/mviner000/eyygo/settings"
 	"gorm.io/gorm"
 )
 
 type ViewHandler struct {
 	DB *gorm.DB
+
+	// Databases gives access to named databases, e.g. Databases.Using("analytics")
+	Databases *settings.Databases
 }
 
 func NewViewHandler(db *gorm.DB) *ViewHandler {
@@ -67,7 +74,7 @@ func (h *ViewHandler) ValidateLogin(c *fiber.Ctx) error {
 
 	// Check user credentials
 	var user models.User
-	if err := h.DB.Where("username = ?", username).First(&user).Error; err != nil {
+	if err := h.DB.WithContext(c.UserContext()).Where("username = ?", username).First(&user).Error; err != nil {
 		return c.Status(401).SendString(`
             <div class="text-red-500 text-sm mt-1">
                 Invalid credentials
@@ -95,8 +102,8 @@ func (h *ViewHandler) Dashboard(c *fiber.Ctx) error {
 	var userCount int64
 	var noteCount int64
 
-	h.DB.Model(&models.User{}).Count(&userCount)
-	h.DB.Model(&models.Note{}).Count(&noteCount)
+	h.DB.WithContext(c.UserContext()).Model(&models.User{}).Count(&userCount)
+	h.DB.WithContext(c.UserContext()).Model(&models.Note{}).Count(&noteCount)
 
 	return c.Render("dashboard", fiber.Map{
 		"Title":     "Dashboard",
@@ -108,7 +115,7 @@ func (h *ViewHandler) Dashboard(c *fiber.Ctx) error {
 // UsersList handles the HTMX request for users list
 func (h *ViewHandler) UsersList(c *fiber.Ctx) error {
 	var users []models.User
-	result := h.DB.Find(&users)
+	result := h.DB.WithContext(c.UserContext()).Find(&users)
 	if result.Error != nil {
 		return c.Status(500).SendString("Error loading users")
 	}
@@ -121,7 +128,10 @@ func (h *ViewHandler) UsersList(c *fiber.Ctx) error {
 // NotesList handles the HTMX request for notes list
 func (h *ViewHandler) NotesList(c *fiber.Ctx) error {
 	var notes []models.Note
-	result := h.DB.Find(&notes)
+	userID, _ := middleware.CurrentUserID(c)
+	result := h.DB.WithContext(c.UserContext()).
+		Scopes(models.VisibleNotes(userID, middleware.IsStaff(c))).
+		Find(&notes)
 	if result.Error != nil {
 		return c.Status(500).SendString("Error loading notes")
 	}
@@ -131,6 +141,29 @@ func (h *ViewHandler) NotesList(c *fiber.Ctx) error {
 	})
 }
 
+// MarkdownPreview handles the HTMX request of a Markdown editor: the form
+// field (or JSON member) named by ?field=, "content" by default, is rendered
+// to sanitized HTML for the preview
+func (h *ViewHandler) MarkdownPreview(c *fiber.Ctx) error {
+	field := c.Query("field", "content")
+	source := c.FormValue(field)
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+		var body map[string]string
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(400).SendString("Invalid request body")
+		}
+		source = body[field]
+	}
+
+	rendered, err := markdown.Render(source)
+	if err != nil {
+		return c.Status(500).SendString("Error rendering Markdown")
+	}
+
+	c.Type("html")
+	return c.SendString(string(rendered))
+}
+
 // LogoutPage handles user logout
 func (h *ViewHandler) LogoutPage(c *fiber.Ctx) error {
 	// Clear session/token here